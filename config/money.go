@@ -0,0 +1,8 @@
+package config
+
+import "strings"
+
+// Currency is the ISO 4217 code every amount in the system is expressed in.
+func Currency() string {
+	return strings.ToUpper(getEnv("CURRENCY", "INR"))
+}
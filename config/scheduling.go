@@ -0,0 +1,69 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("config: Ignoring invalid integer %q for %s, using %d", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// DoctorBufferTime is the minimum gap kept between two procedures of the same doctor.
+func DoctorBufferTime() time.Duration {
+	return time.Duration(getEnvInt("SURGERY_BUFFER_MINUTES", 15)) * time.Minute
+}
+
+// WorkingDay returns the bookable hours for the calendar day of date, in local time.
+func WorkingDay(date time.Time) (time.Time, time.Time) {
+	y, m, d := date.Date()
+	start := time.Date(y, m, d, getEnvInt("WORKDAY_START_HOUR", 8), 0, 0, 0, time.Local)
+	end := time.Date(y, m, d, getEnvInt("WORKDAY_END_HOUR", 20), 0, 0, 0, time.Local)
+	return start, end
+}
+
+// OTTurnoverTime is the cleaning and sterilization interval blocked after each
+// surgery before the theater can be used again.
+func OTTurnoverTime() time.Duration {
+	return time.Duration(getEnvInt("OT_TURNOVER_MINUTES", 30)) * time.Minute
+}
+
+// WaitlistWindow is how long after the requested time a waitlisted request
+// still accepts a slot when it does not give its own preferred range.
+func WaitlistWindow() time.Duration {
+	return time.Duration(getEnvInt("WAITLIST_WINDOW_DAYS", 7)) * 24 * time.Hour
+}
+
+// WaitlistOfferTimeout is how long a doctor has to accept a freed slot offered
+// to a waitlisted request before it goes back to the waitlist.
+func WaitlistOfferTimeout() time.Duration {
+	return time.Duration(getEnvInt("WAITLIST_OFFER_HOURS", 24)) * time.Hour
+}
+
+// CalendarDomain is the domain part of the UIDs in iCalendar feeds. It must
+// stay the same for calendar clients to match updates to earlier events.
+func CalendarDomain() string {
+	return getEnv("CALENDAR_UID_DOMAIN", "crud-hospital-go")
+}
+
+// CalendarHistory is how far back iCalendar feeds include past surgeries.
+func CalendarHistory() time.Duration {
+	return time.Duration(getEnvInt("CALENDAR_HISTORY_DAYS", 30)) * 24 * time.Hour
+}
+
+// AppointmentSlot is the length of one outpatient appointment slot. Bookings
+// start on a slot boundary and last a whole number of slots.
+func AppointmentSlot() time.Duration {
+	return time.Duration(getEnvInt("APPOINTMENT_SLOT_MINUTES", 15)) * time.Minute
+}
@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errAdmissionNotFound = errors.New("admission not found")
+	errPatientAdmitted   = errors.New("patient is already admitted")
+	errNotAdmitted       = errors.New("patient is no longer admitted")
+)
+
+func admissionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errAdmissionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNoBedAvailable), errors.Is(err, errPatientAdmitted), errors.Is(err, errNotAdmitted):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// lockAdmission locks an admission that is still open.
+func lockAdmission(tx *gorm.DB, admissionID string) (models.Admission, error) {
+	var admission models.Admission
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&admission, "id = ?", admissionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return admission, errAdmissionNotFound
+		}
+		return admission, err
+	}
+	if admission.Status != models.AdmissionStatusAdmitted {
+		return admission, fmt.Errorf("%w: admission %d was discharged at %s", errNotAdmitted, admission.ID, admission.DischargedAt.Format(time.RFC3339))
+	}
+	return admission, nil
+}
+
+// reservedBedInWard returns the bed held in the ward for one of the patient's
+// surgeries, so a post-op admission lands in the bed reserved for it.
+func reservedBedInWard(tx *gorm.DB, patientID, wardID uint, now time.Time) (*uint, error) {
+	var reservation models.BedReservation
+	err := activeBedReservations(tx.Model(&models.BedReservation{})).
+		Where("patient_id = ? AND end_at > ?", patientID, now).
+		Where("bed_id IN (?)", tx.Session(&gorm.Session{NewDB: true}).Model(&models.Bed{}).Select("id").Where("ward_id = ?", wardID)).
+		Order("start_at").
+		First(&reservation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reservation.BedID, nil
+}
+
+// fulfilBedReservations marks the patient's reservations of the bed as taken
+// up by the admission and links the admission to the surgery they were for.
+func fulfilBedReservations(tx *gorm.DB, admission *models.Admission, now time.Time) error {
+	var reservations []models.BedReservation
+	if err := activeBedReservations(tx).
+		Where("patient_id = ? AND bed_id = ? AND end_at > ?", admission.PatientID, admission.BedID, now).
+		Order("start_at").
+		Find(&reservations).Error; err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		if err := tx.Model(&models.BedReservation{}).Where("id = ?", reservation.ID).
+			Updates(map[string]interface{}{"status": models.BedReservationFulfilled, "admission_id": admission.ID}).Error; err != nil {
+			return err
+		}
+		if admission.SurgeryScheduleID == nil {
+			surgeryID := reservation.SurgeryScheduleID
+			admission.SurgeryScheduleID = &surgeryID
+		}
+	}
+	if len(reservations) == 0 {
+		return nil
+	}
+	return tx.Omit(clause.Associations).Save(admission).Error
+}
+
+func AdmitPatient(c *gin.Context) {
+	log.Println("AdmitPatient: Request received")
+
+	var request models.AdmissionRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("AdmitPatient: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if request.ExpectedDischargeAt != nil && !request.ExpectedDischargeAt.After(now) {
+		log.Println("AdmitPatient: Expected discharge is in the past")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expected_discharge_at must be in the future"})
+		return
+	}
+
+	var admission models.Admission
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockPatient(tx, request.PatientID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("AdmitPatient: Patient not found with ID %d", request.PatientID)
+				return errors.New("patient not found")
+			}
+			return err
+		}
+
+		var current models.Admission
+		err := tx.Where("patient_id = ? AND status = ?", request.PatientID, models.AdmissionStatusAdmitted).First(&current).Error
+		if err == nil {
+			return fmt.Errorf("%w: admission %d, transfer or discharge it instead", errPatientAdmitted, current.ID)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		bedID := request.BedID
+		if bedID == nil {
+			if bedID, err = reservedBedInWard(tx, request.PatientID, request.WardID, now); err != nil {
+				return err
+			}
+		}
+
+		hold := bedHold{Start: now, Until: request.ExpectedDischargeAt, PatientID: request.PatientID}
+		bed, err := selectBed(tx, request.WardID, bedID, hold)
+		if err != nil && request.BedID == nil && bedID != nil {
+			log.Printf("AdmitPatient: Reserved bed %d unusable (%v), looking for another", *bedID, err)
+			bed, err = selectBed(tx, request.WardID, nil, hold)
+		}
+		if err != nil {
+			log.Printf("AdmitPatient: No bed for patient %d - %v", request.PatientID, err)
+			return err
+		}
+
+		admission = models.Admission{
+			PatientID:           request.PatientID,
+			WardID:              bed.WardID,
+			BedID:               bed.ID,
+			DoctorID:            request.DoctorID,
+			Status:              models.AdmissionStatusAdmitted,
+			Reason:              request.Reason,
+			AdmittedAt:          now,
+			ExpectedDischargeAt: request.ExpectedDischargeAt,
+		}
+		if err := tx.Create(&admission).Error; err != nil {
+			log.Printf("AdmitPatient: Failed to create admission - %v", err)
+			return errors.New("failed to create admission")
+		}
+
+		if err := fulfilBedReservations(tx, &admission, now); err != nil {
+			log.Printf("AdmitPatient: Failed to fulfil bed reservations - %v", err)
+			return errors.New("failed to fulfil bed reservation")
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("AdmitPatient: Transaction failed - %v", err)
+		c.JSON(admissionErrorStatus(err), gin.H{
+			"error":   "Failed to admit patient",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Bed").First(&admission, admission.ID)
+
+	log.Printf("AdmitPatient: Patient %d admitted to bed %d of ward %d (admission %d)", admission.PatientID, admission.BedID, admission.WardID, admission.ID)
+	c.JSON(http.StatusCreated, admission)
+}
+
+func GetAllAdmissions(c *gin.Context) {
+	log.Printf("GetAllAdmissions: Request received for status=%q ward_id=%q", c.Query("status"), c.Query("ward_id"))
+
+	query := config.DB.Preload("Patient").Preload("Bed").Order("admitted_at")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if wardID := c.Query("ward_id"); wardID != "" {
+		query = query.Where("ward_id = ?", wardID)
+	}
+
+	var admissions []models.Admission
+
+	if err := query.Find(&admissions).Error; err != nil {
+		log.Printf("GetAllAdmissions: Error fetching admissions - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetAllAdmissions: Found %d admissions", len(admissions))
+	c.JSON(http.StatusOK, admissions)
+}
+
+func GetAdmissionByID(c *gin.Context) {
+	log.Printf("GetAdmissionByID: Request received for ID %s", c.Param("id"))
+
+	var admission models.Admission
+
+	if err := config.DB.Preload("Patient").Preload("Bed").Preload("Transfers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("transferred_at")
+	}).First(&admission, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GetAdmissionByID: Admission not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admission not found!"})
+		return
+	}
+
+	log.Printf("GetAdmissionByID: Admission found with ID %d", admission.ID)
+	c.JSON(http.StatusOK, admission)
+}
+
+func GetPatientAdmissions(c *gin.Context) {
+	log.Printf("GetPatientAdmissions: Request received for patient ID %s", c.Param("id"))
+
+	var admissions []models.Admission
+
+	if err := config.DB.Preload("Bed").Where("patient_id = ?", c.Param("id")).Order("admitted_at").Find(&admissions).Error; err != nil {
+		log.Printf("GetPatientAdmissions: Error fetching admissions - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetPatientAdmissions: Found %d admissions for patient %s", len(admissions), c.Param("id"))
+	c.JSON(http.StatusOK, admissions)
+}
+
+func TransferAdmission(c *gin.Context) {
+	admissionID := c.Param("id")
+	log.Printf("TransferAdmission: Request received for admission ID %s", admissionID)
+
+	var request models.BedTransferRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("TransferAdmission: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var admission models.Admission
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		admission, err = lockAdmission(tx, admissionID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		bed, err := selectBed(tx, request.WardID, request.BedID, bedHold{
+			Start:        now,
+			Until:        admission.ExpectedDischargeAt,
+			PatientID:    admission.PatientID,
+			CurrentBedID: admission.BedID,
+		})
+		if err != nil {
+			log.Printf("TransferAdmission: No bed for admission %d - %v", admission.ID, err)
+			return err
+		}
+
+		transfer := models.BedTransfer{
+			AdmissionID:   admission.ID,
+			FromWardID:    admission.WardID,
+			FromBedID:     admission.BedID,
+			ToWardID:      bed.WardID,
+			ToBedID:       bed.ID,
+			TransferredAt: now,
+			Reason:        request.Reason,
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			log.Printf("TransferAdmission: Failed to record transfer - %v", err)
+			return errors.New("failed to record transfer")
+		}
+
+		admission.WardID = bed.WardID
+		admission.BedID = bed.ID
+		if err := tx.Omit(clause.Associations).Save(&admission).Error; err != nil {
+			log.Printf("TransferAdmission: Failed to update admission - %v", err)
+			return errors.New("failed to update admission")
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("TransferAdmission: Transaction failed - %v", err)
+		c.JSON(admissionErrorStatus(err), gin.H{
+			"error":   "Failed to transfer patient",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Bed").Preload("Transfers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("transferred_at")
+	}).First(&admission, admission.ID)
+
+	log.Printf("TransferAdmission: Admission %d moved to bed %d of ward %d", admission.ID, admission.BedID, admission.WardID)
+	c.JSON(http.StatusOK, admission)
+}
+
+func DischargeAdmission(c *gin.Context) {
+	admissionID := c.Param("id")
+	log.Printf("DischargeAdmission: Request received for admission ID %s", admissionID)
+
+	var request models.DischargeRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("DischargeAdmission: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Destination != "" && !request.Destination.IsValid() {
+		log.Printf("DischargeAdmission: Invalid discharge destination %q", request.Destination)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid discharge destination %q", request.Destination)})
+		return
+	}
+
+	var admission models.Admission
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		admission, err = lockAdmission(tx, admissionID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		admission.Status = models.AdmissionStatusDischarged
+		admission.DischargedAt = &now
+		admission.DischargeDestination = request.Destination
+		admission.DischargeNotes = request.Notes
+		if err := tx.Omit(clause.Associations).Save(&admission).Error; err != nil {
+			log.Printf("DischargeAdmission: Failed to update admission - %v", err)
+			return errors.New("failed to discharge patient")
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("DischargeAdmission: Transaction failed - %v", err)
+		c.JSON(admissionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("DischargeAdmission: Admission %d discharged, bed %d is free", admission.ID, admission.BedID)
+	c.JSON(http.StatusOK, admission)
+}
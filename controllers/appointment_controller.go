@@ -0,0 +1,470 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errAppointmentNotFound = errors.New("appointment not found")
+	// errPatientUnavailable means the patient already has a surgery or another
+	// appointment at that time.
+	errPatientUnavailable = errors.New("patient unavailable")
+)
+
+func appointmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errAppointmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrInvalidAppointmentTransition),
+		errors.Is(err, errDoctorUnavailable),
+		errors.Is(err, errPatientUnavailable):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// validateAppointmentSlot makes sure the appointment starts on a slot
+// boundary in the future and lasts a whole number of slots.
+func validateAppointmentSlot(slot models.TimeSlot) error {
+	step := config.AppointmentSlot()
+	if !slot.Start.After(time.Now()) {
+		return errors.New("appointments must be booked in the future")
+	}
+	if !alignToStep(slot.Start, step).Equal(slot.Start) {
+		return fmt.Errorf("appointments start on %s slot boundaries", step)
+	}
+	if slot.Duration() <= 0 || slot.Duration()%step != 0 {
+		return fmt.Errorf("duration must be a multiple of %s", step)
+	}
+	return nil
+}
+
+// lockDoctorForAppointment locks the doctor row like a surgery booking and
+// makes sure the doctor is rostered for slot, clear of their surgeries and
+// the buffer around them, and not booked for another appointment.
+func lockDoctorForAppointment(tx *gorm.DB, doctorID uint, slot models.TimeSlot, excludeAppointmentID uint) (models.Doctor, error) {
+	var doctor models.Doctor
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doctor, "id = ?", doctorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return doctor, fmt.Errorf("doctor %d not found", doctorID)
+		}
+		return doctor, err
+	}
+
+	surgery, err := findDoctorConflict(tx, doctor.ID, slot.Start, slot.End, 0)
+	if err != nil {
+		return doctor, err
+	}
+	if surgery != nil {
+		return doctor, fmt.Errorf("%w: doctor %d (%s) has surgery %d from %s to %s (including %s buffer)",
+			errDoctorUnavailable, doctor.ID, doctor.Name, surgery.ID, surgery.ScheduledAt.Format(time.RFC3339), surgery.ScheduledEnd.Format(time.RFC3339), config.DoctorBufferTime())
+	}
+
+	appointment, err := findAppointmentConflict(tx, doctor.ID, slot.Start, slot.End, excludeAppointmentID)
+	if err != nil {
+		return doctor, err
+	}
+	if appointment != nil {
+		return doctor, fmt.Errorf("%w: doctor %d (%s) already has appointment %d from %s to %s",
+			errDoctorUnavailable, doctor.ID, doctor.Name, appointment.ID, appointment.ScheduledAt.Format(time.RFC3339), appointment.ScheduledEnd.Format(time.RFC3339))
+	}
+
+	if err := checkDoctorRostered(tx, doctor, slot); err != nil {
+		return doctor, err
+	}
+	return doctor, nil
+}
+
+// checkPatientFree refuses an appointment or surgery overlapping the patient's
+// other surgeries or appointments. The booking being moved, if any, is left
+// out.
+func checkPatientFree(tx *gorm.DB, patientID uint, slot models.TimeSlot, excludeSurgeryID, excludeAppointmentID uint) error {
+	surgeries := overlappingSurgeries(tx, slot.Start, slot.End).Where("patient_id = ?", patientID)
+	if excludeSurgeryID != 0 {
+		surgeries = surgeries.Where("id <> ?", excludeSurgeryID)
+	}
+	var surgery models.SurgerySchedule
+	err := surgeries.First(&surgery).Error
+	if err == nil {
+		return fmt.Errorf("%w: patient %d has surgery %d from %s to %s",
+			errPatientUnavailable, patientID, surgery.ID, surgery.ScheduledAt.Format(time.RFC3339), surgery.ScheduledEnd.Format(time.RFC3339))
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	query := overlappingAppointments(tx, slot.Start, slot.End).Where("patient_id = ?", patientID)
+	if excludeAppointmentID != 0 {
+		query = query.Where("id <> ?", excludeAppointmentID)
+	}
+	var appointment models.Appointment
+	err = query.First(&appointment).Error
+	if err == nil {
+		return fmt.Errorf("%w: patient %d already has appointment %d from %s to %s",
+			errPatientUnavailable, patientID, appointment.ID, appointment.ScheduledAt.Format(time.RFC3339), appointment.ScheduledEnd.Format(time.RFC3339))
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// transitionAppointment locks the appointment row, applies the state machine
+// and saves the result.
+func transitionAppointment(tx *gorm.DB, appointmentID string, next models.AppointmentStatus) (models.Appointment, error) {
+	var appointment models.Appointment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", appointmentID).
+		First(&appointment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appointment, errAppointmentNotFound
+		}
+		return appointment, err
+	}
+
+	if err := appointment.TransitionTo(next, time.Now()); err != nil {
+		return appointment, err
+	}
+
+	if err := tx.Omit(clause.Associations).Save(&appointment).Error; err != nil {
+		log.Printf("transitionAppointment: Failed to save appointment %d - %v", appointment.ID, err)
+		return appointment, errors.New("failed to update appointment status")
+	}
+	return appointment, nil
+}
+
+func BookAppointment(c *gin.Context) {
+	log.Println("BookAppointment: Request received")
+
+	var request models.AppointmentRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("BookAppointment: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appointment := models.Appointment{
+		PatientID:   request.PatientID,
+		DoctorID:    request.DoctorID,
+		ScheduledAt: request.ScheduledAt,
+		Duration:    request.Duration,
+		Reason:      request.Reason,
+		Notes:       request.Notes,
+		Status:      models.AppointmentStatusBooked,
+	}
+	if appointment.Duration == 0 {
+		appointment.Duration = int(config.AppointmentSlot().Minutes())
+	}
+	slot := appointment.Slot()
+
+	if err := validateAppointmentSlot(slot); err != nil {
+		log.Printf("BookAppointment: Invalid slot - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockDoctorForAppointment(tx, request.DoctorID, slot, 0); err != nil {
+			log.Printf("BookAppointment: Doctor %d unavailable - %v", request.DoctorID, err)
+			return err
+		}
+
+		if _, err := lockPatient(tx, request.PatientID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("BookAppointment: Patient not found with ID %d", request.PatientID)
+				return errors.New("patient not found")
+			}
+			return err
+		}
+		if err := checkPatientFree(tx, request.PatientID, slot, 0, 0); err != nil {
+			log.Printf("BookAppointment: Patient %d unavailable - %v", request.PatientID, err)
+			return err
+		}
+
+		if err := tx.Create(&appointment).Error; err != nil {
+			log.Printf("BookAppointment: Failed to create appointment - %v", err)
+			return errors.New("failed to create appointment")
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("BookAppointment: Transaction failed - %v", err)
+		c.JSON(appointmentErrorStatus(err), gin.H{
+			"error":   "Failed to book appointment",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").First(&appointment, appointment.ID)
+
+	log.Printf("BookAppointment: Appointment booked successfully with ID %d", appointment.ID)
+	c.JSON(http.StatusCreated, appointment)
+}
+
+func GetAllAppointments(c *gin.Context) {
+	log.Printf("GetAllAppointments: Request received for status=%q date=%q", c.Query("status"), c.Query("date"))
+
+	query := config.DB.Preload("Patient").Preload("Doctor").Order("scheduled_at")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if dateStr := c.Query("date"); dateStr != "" {
+		date, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			log.Printf("GetAllAppointments: Invalid date format %s", dateStr)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("scheduled_at >= ? AND scheduled_at < ?", date, date.AddDate(0, 0, 1))
+	}
+
+	var appointments []models.Appointment
+
+	if err := query.Find(&appointments).Error; err != nil {
+		log.Printf("GetAllAppointments: Error fetching appointments - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetAllAppointments: Found %d appointments", len(appointments))
+	c.JSON(http.StatusOK, appointments)
+}
+
+func GetAppointmentByID(c *gin.Context) {
+	log.Printf("GetAppointmentByID: Request received for ID %s", c.Param("id"))
+
+	var appointment models.Appointment
+
+	if err := config.DB.Preload("Patient").Preload("Doctor").First(&appointment, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GetAppointmentByID: Appointment not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found!"})
+		return
+	}
+
+	log.Printf("GetAppointmentByID: Appointment found with ID %d", appointment.ID)
+	c.JSON(http.StatusOK, appointment)
+}
+
+func GetDoctorAppointments(c *gin.Context) {
+	log.Printf("GetDoctorAppointments: Request received for doctor ID %s", c.Param("id"))
+
+	query := config.DB.Preload("Patient").Where("doctor_id = ?", c.Param("id")).Order("scheduled_at")
+	if dateStr := c.Query("date"); dateStr != "" {
+		date, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			log.Printf("GetDoctorAppointments: Invalid date format %s", dateStr)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("scheduled_at >= ? AND scheduled_at < ?", date, date.AddDate(0, 0, 1))
+	}
+
+	var appointments []models.Appointment
+
+	if err := query.Find(&appointments).Error; err != nil {
+		log.Printf("GetDoctorAppointments: Error fetching appointments - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetDoctorAppointments: Found %d appointments for doctor %s", len(appointments), c.Param("id"))
+	c.JSON(http.StatusOK, appointments)
+}
+
+func GetPatientAppointments(c *gin.Context) {
+	log.Printf("GetPatientAppointments: Request received for patient ID %s", c.Param("id"))
+
+	var appointments []models.Appointment
+
+	if err := config.DB.Preload("Doctor").Where("patient_id = ?", c.Param("id")).Order("scheduled_at").Find(&appointments).Error; err != nil {
+		log.Printf("GetPatientAppointments: Error fetching appointments - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetPatientAppointments: Found %d appointments for patient %s", len(appointments), c.Param("id"))
+	c.JSON(http.StatusOK, appointments)
+}
+
+// GetDoctorAppointmentSlots lists the bookable appointment slots of a day:
+// rostered time not taken by appointments or by surgeries and their buffer.
+func GetDoctorAppointmentSlots(c *gin.Context) {
+	doctor, ok := findRosterDoctor(c, "GetDoctorAppointmentSlots")
+	if !ok {
+		return
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", c.Query("date"), time.Local)
+	if err != nil {
+		log.Printf("GetDoctorAppointmentSlots: Invalid date format %s", c.Query("date"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+		return
+	}
+	window := models.TimeSlot{Start: date, End: date.AddDate(0, 0, 1)}
+	step := config.AppointmentSlot()
+
+	rostered, err := doctorRosteredSlots(config.DB, doctor.ID, window)
+	if err != nil {
+		log.Printf("GetDoctorAppointmentSlots: Error computing roster - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	busy, err := doctorSurgerySlots(config.DB, doctor.ID, window)
+	if err != nil {
+		log.Printf("GetDoctorAppointmentSlots: Error fetching surgeries - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	appointments, err := doctorAppointmentSlots(config.DB, doctor.ID, window, 0)
+	if err != nil {
+		log.Printf("GetDoctorAppointmentSlots: Error fetching appointments - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	busy = append(busy, appointments...)
+
+	now := time.Now()
+	slots := []models.TimeSlot{}
+	for _, roster := range rostered {
+		for _, free := range subtractSlots(roster, busy, step) {
+			for start := alignToStep(free.Start, step); !start.Add(step).After(free.End); start = start.Add(step) {
+				if start.After(now) {
+					slots = append(slots, models.TimeSlot{Start: start, End: start.Add(step)})
+				}
+			}
+		}
+	}
+
+	log.Printf("GetDoctorAppointmentSlots: Doctor %d has %d free slots on %s", doctor.ID, len(slots), c.Query("date"))
+	c.JSON(http.StatusOK, gin.H{
+		"doctor_id":    doctor.ID,
+		"doctor_name":  doctor.Name,
+		"date":         c.Query("date"),
+		"slot_minutes": int(step.Minutes()),
+		"slots":        slots,
+	})
+}
+
+func RescheduleAppointment(c *gin.Context) {
+	appointmentID := c.Param("id")
+	log.Printf("RescheduleAppointment: Request received for appointment ID %s", appointmentID)
+
+	var request models.AppointmentRescheduleRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("RescheduleAppointment: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var appointment models.Appointment
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&appointment, "id = ?", appointmentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errAppointmentNotFound
+			}
+			return err
+		}
+		if appointment.Status != models.AppointmentStatusBooked {
+			return fmt.Errorf("%w: cannot reschedule appointment %d in status %s", models.ErrInvalidAppointmentTransition, appointment.ID, appointment.Status)
+		}
+
+		if request.ScheduledAt != nil {
+			appointment.ScheduledAt = *request.ScheduledAt
+		}
+		if request.Duration != nil {
+			appointment.Duration = *request.Duration
+		}
+		if request.DoctorID != nil {
+			appointment.DoctorID = *request.DoctorID
+		}
+		slot := appointment.Slot()
+
+		if err := validateAppointmentSlot(slot); err != nil {
+			return err
+		}
+		if _, err := lockDoctorForAppointment(tx, appointment.DoctorID, slot, appointment.ID); err != nil {
+			log.Printf("RescheduleAppointment: Doctor %d unavailable - %v", appointment.DoctorID, err)
+			return err
+		}
+		if err := checkPatientFree(tx, appointment.PatientID, slot, 0, appointment.ID); err != nil {
+			log.Printf("RescheduleAppointment: Patient %d unavailable - %v", appointment.PatientID, err)
+			return err
+		}
+
+		if err := tx.Omit(clause.Associations).Save(&appointment).Error; err != nil {
+			log.Printf("RescheduleAppointment: Failed to update appointment - %v", err)
+			return errors.New("failed to update appointment")
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("RescheduleAppointment: Transaction failed - %v", err)
+		c.JSON(appointmentErrorStatus(err), gin.H{
+			"error":   "Failed to reschedule appointment",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").First(&appointment, appointment.ID)
+
+	log.Printf("RescheduleAppointment: Appointment %d moved to %s with doctor %d", appointment.ID, appointment.ScheduledAt.Format(time.RFC3339), appointment.DoctorID)
+	c.JSON(http.StatusOK, appointment)
+}
+
+// updateAppointmentStatus runs one lifecycle transition for the handlers below.
+func updateAppointmentStatus(c *gin.Context, handler string, next models.AppointmentStatus) {
+	appointmentID := c.Param("id")
+	log.Printf("%s: Request received for appointment ID %s", handler, appointmentID)
+
+	var appointment models.Appointment
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		appointment, err = transitionAppointment(tx, appointmentID, next)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("%s: Transaction failed - %v", handler, err)
+		c.JSON(appointmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("%s: Appointment %d is now %s", handler, appointment.ID, appointment.Status)
+	c.JSON(http.StatusOK, appointment)
+}
+
+func CheckInAppointment(c *gin.Context) {
+	updateAppointmentStatus(c, "CheckInAppointment", models.AppointmentStatusCheckedIn)
+}
+
+func MarkAppointmentSeen(c *gin.Context) {
+	updateAppointmentStatus(c, "MarkAppointmentSeen", models.AppointmentStatusSeen)
+}
+
+func MarkAppointmentNoShow(c *gin.Context) {
+	updateAppointmentStatus(c, "MarkAppointmentNoShow", models.AppointmentStatusNoShow)
+}
+
+func CancelAppointment(c *gin.Context) {
+	updateAppointmentStatus(c, "CancelAppointment", models.AppointmentStatusCancelled)
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"CRUD-hospital-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errNoBedAvailable means the ward has suitable beds but none is free for the
+// requested stay, so the same request may succeed later.
+var errNoBedAvailable = errors.New("no bed available")
+
+// bedHold describes the stay a bed is wanted for. Until is nil for an
+// open-ended admission. Admissions and reservations of the patient
+// themselves never get in the way, and CurrentBedID, the bed a transfer
+// moves out of, is never picked.
+type bedHold struct {
+	Start                time.Time
+	Until                *time.Time
+	PatientID            uint
+	CurrentBedID         uint
+	ExcludeReservationID uint
+}
+
+func (h bedHold) String() string {
+	if h.Until == nil {
+		return fmt.Sprintf("from %s", h.Start.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("from %s to %s", h.Start.Format(time.RFC3339), h.Until.Format(time.RFC3339))
+}
+
+// activeBedReservations scopes a query to held reservations whose surgery
+// still needs the bed.
+func activeBedReservations(tx *gorm.DB) *gorm.DB {
+	released := tx.Session(&gorm.Session{NewDB: true}).Model(&models.SurgerySchedule{}).
+		Select("id").
+		Where("status IN ?", models.BedReleasingSurgeryStatuses)
+	return tx.Where("status = ? AND surgery_schedule_id NOT IN (?)", models.BedReservationHeld, released)
+}
+
+// findBedConflict explains why bed cannot be used for hold, or returns an
+// empty string when it can. An admission blocks its bed until the expected
+// discharge, or for as long as the patient is still there past it.
+func findBedConflict(tx *gorm.DB, bed models.Bed, hold bedHold) (string, error) {
+	if bed.Status == models.BedStatusMaintenance {
+		return "it is under maintenance", nil
+	}
+
+	var admission models.Admission
+	err := tx.Where("bed_id = ? AND status = ? AND patient_id <> ?", bed.ID, models.AdmissionStatusAdmitted, hold.PatientID).
+		Where("expected_discharge_at IS NULL OR expected_discharge_at > ? OR expected_discharge_at <= ?", hold.Start, time.Now()).
+		First(&admission).Error
+	if err == nil {
+		return fmt.Sprintf("patient %d is admitted to it (admission %d)", admission.PatientID, admission.ID), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	query := activeBedReservations(tx.Model(&models.BedReservation{})).
+		Where("bed_id = ? AND patient_id <> ? AND end_at > ?", bed.ID, hold.PatientID, hold.Start)
+	if hold.Until != nil {
+		query = query.Where("start_at < ?", *hold.Until)
+	}
+	if hold.ExcludeReservationID != 0 {
+		query = query.Where("id <> ?", hold.ExcludeReservationID)
+	}
+	var reservation models.BedReservation
+	err = query.Order("start_at").First(&reservation).Error
+	if err == nil {
+		return fmt.Sprintf("it is reserved for surgery %d from %s to %s",
+			reservation.SurgeryScheduleID, reservation.StartAt.Format(time.RFC3339), reservation.EndAt.Format(time.RFC3339)), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	return "", nil
+}
+
+// selectBed locks the beds of the ward, or only the pinned one, and returns
+// the first that is free for hold, so concurrent admissions and reservations
+// of the same ward are serialized like theater bookings.
+func selectBed(tx *gorm.DB, wardID uint, bedID *uint, hold bedHold) (models.Bed, error) {
+	var ward models.Ward
+	if err := tx.First(&ward, "id = ?", wardID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Bed{}, fmt.Errorf("ward %d not found", wardID)
+		}
+		return models.Bed{}, err
+	}
+
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("ward_id = ?", ward.ID).Order("id")
+	if bedID != nil {
+		query = query.Where("id = ?", *bedID)
+	}
+	var beds []models.Bed
+	if err := query.Find(&beds).Error; err != nil {
+		return models.Bed{}, err
+	}
+	if bedID != nil && len(beds) == 0 {
+		return models.Bed{}, fmt.Errorf("bed %d not found in ward %d", *bedID, ward.ID)
+	}
+	if len(beds) == 0 {
+		return models.Bed{}, fmt.Errorf("ward %d (%s) has no beds", ward.ID, ward.Name)
+	}
+
+	var lastReason string
+	for _, bed := range beds {
+		if bed.ID == hold.CurrentBedID {
+			if bedID != nil {
+				return models.Bed{}, fmt.Errorf("the patient is already in bed %d", bed.ID)
+			}
+			continue
+		}
+		reason, err := findBedConflict(tx, bed, hold)
+		if err != nil {
+			return models.Bed{}, err
+		}
+		if reason == "" {
+			return bed, nil
+		}
+		if bedID != nil {
+			return models.Bed{}, fmt.Errorf("%w: bed %d (%s) cannot be used %s: %s", errNoBedAvailable, bed.ID, bed.Label, hold, reason)
+		}
+		lastReason = fmt.Sprintf("bed %d (%s): %s", bed.ID, bed.Label, reason)
+	}
+	if lastReason == "" {
+		return models.Bed{}, fmt.Errorf("%w: ward %d (%s) has no other bed", errNoBedAvailable, ward.ID, ward.Name)
+	}
+	return models.Bed{}, fmt.Errorf("%w: every bed of ward %d (%s) is taken %s, last checked %s", errNoBedAvailable, ward.ID, ward.Name, hold, lastReason)
+}
+
+// reservePostOpBed holds a bed in the surgery's post-op ward from its planned
+// end for PostOpNights nights. A surgery that moved keeps its bed when it is
+// still free and otherwise gets another one in the same ward.
+func reservePostOpBed(tx *gorm.DB, surgery *models.SurgerySchedule) error {
+	if surgery.PostOpWardID == nil {
+		return nil
+	}
+
+	var reservation models.BedReservation
+	err := tx.Where("surgery_schedule_id = ?", surgery.ID).First(&reservation).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if reservation.Status == models.BedReservationFulfilled {
+		return nil
+	}
+
+	start := surgery.Slot().End
+	until := start.AddDate(0, 0, surgery.PostOpNights)
+	hold := bedHold{Start: start, Until: &until, PatientID: surgery.PatientID, ExcludeReservationID: reservation.ID}
+
+	var bed models.Bed
+	if reservation.ID != 0 {
+		bed, err = selectBed(tx, *surgery.PostOpWardID, &reservation.BedID, hold)
+	}
+	if reservation.ID == 0 || err != nil {
+		bed, err = selectBed(tx, *surgery.PostOpWardID, nil, hold)
+	}
+	if err != nil {
+		log.Printf("reservePostOpBed: No post-op bed for surgery %d - %v", surgery.ID, err)
+		return err
+	}
+
+	reservation.BedID = bed.ID
+	reservation.SurgeryScheduleID = surgery.ID
+	reservation.PatientID = surgery.PatientID
+	reservation.StartAt = start
+	reservation.EndAt = until
+	reservation.Status = models.BedReservationHeld
+	return tx.Omit(clause.Associations).Save(&reservation).Error
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CreateCancellationPolicyRule(c *gin.Context) {
+	log.Println("CreateCancellationPolicyRule: Request received")
+
+	var input models.CancellationPolicyRule
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateCancellationPolicyRule: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.SurgeryTypeID != nil {
+		var surgeryType models.SurgeryType
+		if err := config.DB.First(&surgeryType, "id = ?", *input.SurgeryTypeID).Error; err != nil {
+			log.Printf("CreateCancellationPolicyRule: Surgery type not found with ID %d", *input.SurgeryTypeID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Surgery type not found!"})
+			return
+		}
+	}
+
+	config.DB.Create(&input)
+
+	log.Printf("CreateCancellationPolicyRule: Rule created successfully with ID %d", input.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func GetCancellationPolicyRules(c *gin.Context) {
+	log.Printf("GetCancellationPolicyRules: Request received for surgery_type_id %q", c.Query("surgery_type_id"))
+
+	var rules []models.CancellationPolicyRule
+
+	query := config.DB.Order("surgery_type_id").Order("min_hours_before DESC")
+	if surgeryTypeID := c.Query("surgery_type_id"); surgeryTypeID != "" {
+		query = query.Where("surgery_type_id = ?", surgeryTypeID)
+	}
+
+	if err := query.Find(&rules).Error; err != nil {
+		log.Printf("GetCancellationPolicyRules: Error fetching rules - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetCancellationPolicyRules: Found %d rules", len(rules))
+	c.JSON(http.StatusOK, rules)
+}
+
+func UpdateCancellationPolicyRule(c *gin.Context) {
+	log.Printf("UpdateCancellationPolicyRule: Request received for ID %s", c.Param("id"))
+
+	var rule models.CancellationPolicyRule
+	id := c.Param("id")
+
+	if err := config.DB.First(&rule, "id = ?", id).Error; err != nil {
+		log.Printf("UpdateCancellationPolicyRule: Rule not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cancellation policy rule not found!"})
+		return
+	}
+
+	var input struct {
+		Name           *string `json:"name"`
+		MinHoursBefore *int    `json:"min_hours_before"`
+		RefundPercent  *int    `json:"refund_percent" binding:"omitempty,gte=0,lte=100"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateCancellationPolicyRule: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		rule.Name = *input.Name
+	}
+	if input.MinHoursBefore != nil {
+		rule.MinHoursBefore = *input.MinHoursBefore
+	}
+	if input.RefundPercent != nil {
+		rule.RefundPercent = *input.RefundPercent
+	}
+	rule.UpdatedAt = time.Now()
+
+	config.DB.Save(&rule)
+	log.Printf("UpdateCancellationPolicyRule: Rule updated successfully with ID %s", id)
+	c.JSON(http.StatusOK, rule)
+}
+
+func DeleteCancellationPolicyRule(c *gin.Context) {
+	log.Printf("DeleteCancellationPolicyRule: Request received for ID %s", c.Param("id"))
+
+	var rule models.CancellationPolicyRule
+	id := c.Param("id")
+
+	if err := config.DB.First(&rule, "id = ?", id).Error; err != nil {
+		log.Printf("DeleteCancellationPolicyRule: Rule not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cancellation policy rule not found!"})
+		return
+	}
+
+	config.DB.Delete(&rule)
+	log.Printf("DeleteCancellationPolicyRule: Rule deleted successfully with ID %s", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Cancellation policy rule deleted successfully"})
+}
@@ -0,0 +1,363 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// errChecklistIncomplete blocks starting a surgery with mandatory pre-op
+	// checks still open.
+	errChecklistIncomplete   = errors.New("pre-operative checklist incomplete")
+	errChecklistItemNotFound = errors.New("checklist item not found")
+	// errChecklistItemMandatory keeps mandatory checks from being deleted to
+	// get past the start gate; they have to be completed instead.
+	errChecklistItemMandatory = errors.New("mandatory checklist items cannot be deleted")
+)
+
+func orderedChecklist(db *gorm.DB) *gorm.DB {
+	return db.Order("position").Order("id")
+}
+
+// createSurgeryChecklist copies the checklist templates of the surgery type,
+// and those shared by every type, onto a newly booked surgery.
+func createSurgeryChecklist(tx *gorm.DB, surgeryID uint, surgeryType models.SurgeryType) error {
+	var templates []models.ChecklistTemplateItem
+	if err := orderedChecklist(tx.Where("surgery_type_id = ? OR surgery_type_id IS NULL", surgeryType.ID)).
+		Find(&templates).Error; err != nil {
+		return err
+	}
+
+	for _, template := range templates {
+		templateID := template.ID
+		item := models.SurgeryChecklistItem{
+			SurgeryScheduleID: surgeryID,
+			TemplateItemID:    &templateID,
+			Name:              template.Name,
+			Category:          template.Category,
+			Mandatory:         template.Mandatory,
+			Position:          template.Position,
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSurgeryReadiness fails with errChecklistIncomplete naming the
+// mandatory items still open.
+func checkSurgeryReadiness(tx *gorm.DB, surgeryID uint) error {
+	var outstanding []models.SurgeryChecklistItem
+	if err := orderedChecklist(tx.Where("surgery_schedule_id = ? AND mandatory = ? AND completed_at IS NULL", surgeryID, true)).
+		Find(&outstanding).Error; err != nil {
+		return err
+	}
+	if len(outstanding) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(outstanding))
+	for _, item := range outstanding {
+		names = append(names, item.Name)
+	}
+	return fmt.Errorf("%w: surgery %d is waiting on %s", errChecklistIncomplete, surgeryID, strings.Join(names, ", "))
+}
+
+// lockChecklistSurgery locks a surgery whose checklist may still change.
+func lockChecklistSurgery(tx *gorm.DB, surgeryID string) (models.SurgerySchedule, error) {
+	var surgery models.SurgerySchedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&surgery, "id = ?", surgeryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return surgery, errSurgeryNotFound
+		}
+		return surgery, err
+	}
+	if surgery.Status != models.SurgeryStatusScheduled && surgery.Status != models.SurgeryStatusPostponed {
+		return surgery, fmt.Errorf("%w: the checklist of surgery %d cannot change once it is %s", models.ErrInvalidSurgeryTransition, surgery.ID, surgery.Status)
+	}
+	return surgery, nil
+}
+
+func CreateChecklistTemplateItem(c *gin.Context) {
+	log.Println("CreateChecklistTemplateItem: Request received")
+
+	var input models.ChecklistTemplateItem
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateChecklistTemplateItem: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.SurgeryTypeID != nil {
+		var surgeryType models.SurgeryType
+		if err := config.DB.First(&surgeryType, "id = ?", *input.SurgeryTypeID).Error; err != nil {
+			log.Printf("CreateChecklistTemplateItem: Surgery type not found with ID %d", *input.SurgeryTypeID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Surgery type not found!"})
+			return
+		}
+	}
+
+	config.DB.Create(&input)
+
+	log.Printf("CreateChecklistTemplateItem: Template item created successfully with ID %d", input.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func GetChecklistTemplateItems(c *gin.Context) {
+	log.Printf("GetChecklistTemplateItems: Request received for surgery_type_id %q", c.Query("surgery_type_id"))
+
+	var items []models.ChecklistTemplateItem
+
+	query := config.DB.Order("surgery_type_id").Order("position").Order("id")
+	if surgeryTypeID := c.Query("surgery_type_id"); surgeryTypeID != "" {
+		query = query.Where("surgery_type_id = ? OR surgery_type_id IS NULL", surgeryTypeID)
+	}
+
+	if err := query.Find(&items).Error; err != nil {
+		log.Printf("GetChecklistTemplateItems: Error fetching template items - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetChecklistTemplateItems: Found %d template items", len(items))
+	c.JSON(http.StatusOK, items)
+}
+
+func UpdateChecklistTemplateItem(c *gin.Context) {
+	log.Printf("UpdateChecklistTemplateItem: Request received for ID %s", c.Param("id"))
+
+	var item models.ChecklistTemplateItem
+	id := c.Param("id")
+
+	if err := config.DB.First(&item, "id = ?", id).Error; err != nil {
+		log.Printf("UpdateChecklistTemplateItem: Template item not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checklist template item not found!"})
+		return
+	}
+
+	var input struct {
+		Name      *string `json:"name"`
+		Category  *string `json:"category"`
+		Mandatory *bool   `json:"mandatory"`
+		Position  *int    `json:"position"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateChecklistTemplateItem: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		item.Name = *input.Name
+	}
+	if input.Category != nil {
+		item.Category = *input.Category
+	}
+	if input.Mandatory != nil {
+		item.Mandatory = *input.Mandatory
+	}
+	if input.Position != nil {
+		item.Position = *input.Position
+	}
+	item.UpdatedAt = time.Now()
+
+	config.DB.Save(&item)
+	log.Printf("UpdateChecklistTemplateItem: Template item updated successfully with ID %s", id)
+	c.JSON(http.StatusOK, item)
+}
+
+func DeleteChecklistTemplateItem(c *gin.Context) {
+	log.Printf("DeleteChecklistTemplateItem: Request received for ID %s", c.Param("id"))
+
+	var item models.ChecklistTemplateItem
+	id := c.Param("id")
+
+	if err := config.DB.First(&item, "id = ?", id).Error; err != nil {
+		log.Printf("DeleteChecklistTemplateItem: Template item not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checklist template item not found!"})
+		return
+	}
+
+	config.DB.Delete(&item)
+	log.Printf("DeleteChecklistTemplateItem: Template item deleted successfully with ID %s", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Checklist template item deleted successfully"})
+}
+
+func GetSurgeryChecklist(c *gin.Context) {
+	log.Printf("GetSurgeryChecklist: Request received for surgery ID %s", c.Param("id"))
+
+	var surgery models.SurgerySchedule
+
+	if err := config.DB.Preload("Checklist", orderedChecklist).First(&surgery, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GetSurgeryChecklist: Surgery not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Surgery not found!"})
+		return
+	}
+
+	outstanding := surgery.OutstandingChecklistItems()
+
+	log.Printf("GetSurgeryChecklist: Surgery %d has %d items, %d outstanding", surgery.ID, len(surgery.Checklist), len(outstanding))
+	c.JSON(http.StatusOK, gin.H{
+		"surgery_schedule_id": surgery.ID,
+		"ready":               len(outstanding) == 0,
+		"items":               surgery.Checklist,
+		"outstanding":         outstanding,
+	})
+}
+
+func AddSurgeryChecklistItem(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("AddSurgeryChecklistItem: Request received for surgery ID %s", surgeryID)
+
+	var input struct {
+		Name      string `json:"name" binding:"required"`
+		Category  string `json:"category"`
+		Mandatory bool   `json:"mandatory"`
+		Position  int    `json:"position"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("AddSurgeryChecklistItem: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item models.SurgeryChecklistItem
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		surgery, err := lockChecklistSurgery(tx, surgeryID)
+		if err != nil {
+			return err
+		}
+		item = models.SurgeryChecklistItem{
+			SurgeryScheduleID: surgery.ID,
+			Name:              input.Name,
+			Category:          input.Category,
+			Mandatory:         input.Mandatory,
+			Position:          input.Position,
+		}
+		return tx.Create(&item).Error
+	})
+
+	if err != nil {
+		log.Printf("AddSurgeryChecklistItem: Transaction failed - %v", err)
+		c.JSON(surgeryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("AddSurgeryChecklistItem: Item %d added to surgery %s", item.ID, surgeryID)
+	c.JSON(http.StatusCreated, item)
+}
+
+func UpdateSurgeryChecklistItem(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("UpdateSurgeryChecklistItem: Request received for surgery ID %s, item ID %s", surgeryID, c.Param("item_id"))
+
+	var input models.SurgeryChecklistUpdate
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateSurgeryChecklistItem: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item models.SurgeryChecklistItem
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		surgery, err := lockChecklistSurgery(tx, surgeryID)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&item, "id = ? AND surgery_schedule_id = ?", c.Param("item_id"), surgery.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errChecklistItemNotFound
+			}
+			return err
+		}
+
+		if *input.Completed {
+			if input.CompletedBy == "" {
+				return errors.New("completed_by is required to tick an item off")
+			}
+			now := time.Now()
+			item.CompletedAt = &now
+			item.CompletedBy = input.CompletedBy
+		} else {
+			item.CompletedAt = nil
+			item.CompletedBy = ""
+		}
+		if input.Notes != nil {
+			item.Notes = *input.Notes
+		}
+		return tx.Save(&item).Error
+	})
+
+	if err != nil {
+		log.Printf("UpdateSurgeryChecklistItem: Transaction failed - %v", err)
+		status := surgeryErrorStatus(err)
+		if errors.Is(err, errChecklistItemNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("UpdateSurgeryChecklistItem: Item %d of surgery %s completed: %t", item.ID, surgeryID, item.CompletedAt != nil)
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteSurgeryChecklistItem removes a check that does not apply to the
+// surgery, including one copied from a mandatory template.
+func DeleteSurgeryChecklistItem(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("DeleteSurgeryChecklistItem: Request received for surgery ID %s, item ID %s", surgeryID, c.Param("item_id"))
+
+	var item models.SurgeryChecklistItem
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		surgery, err := lockChecklistSurgery(tx, surgeryID)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&item, "id = ? AND surgery_schedule_id = ?", c.Param("item_id"), surgery.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errChecklistItemNotFound
+			}
+			return err
+		}
+		if item.Mandatory {
+			return fmt.Errorf("%w: item %d (%s)", errChecklistItemMandatory, item.ID, item.Name)
+		}
+		return tx.Delete(&item).Error
+	})
+
+	if err != nil {
+		log.Printf("DeleteSurgeryChecklistItem: Transaction failed - %v", err)
+		status := surgeryErrorStatus(err)
+		if errors.Is(err, errChecklistItemNotFound) {
+			status = http.StatusNotFound
+		}
+		if errors.Is(err, errChecklistItemMandatory) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("DeleteSurgeryChecklistItem: Item %d (%s) removed from surgery %s", item.ID, item.Name, surgeryID)
+	c.JSON(http.StatusOK, gin.H{"message": "Checklist item deleted successfully"})
+}
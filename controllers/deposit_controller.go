@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInsufficientDeposit = errors.New("insufficient patient deposit")
+
+// normalizeCurrency defaults an empty code to the configured currency and
+// rejects any other currency, since amounts are never converted.
+func normalizeCurrency(code string) (string, error) {
+	if code == "" {
+		return config.Currency(), nil
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code != config.Currency() {
+		return "", fmt.Errorf("unsupported currency %s, amounts must be in %s", code, config.Currency())
+	}
+	return code, nil
+}
+
+func depositLedgerBalance(tx *gorm.DB, patientID uint) (models.Amount, error) {
+	var balance models.Amount
+	err := tx.Model(&models.DepositTransaction{}).
+		Where("patient_id = ?", patientID).
+		Select(models.DepositBalanceSQL).
+		Scan(&balance).Error
+	return balance, err
+}
+
+func depositHeldForSurgery(tx *gorm.DB, surgeryID uint) (models.Amount, error) {
+	var held models.Amount
+	err := tx.Model(&models.DepositTransaction{}).
+		Where("surgery_schedule_id = ?", surgeryID).
+		Select(models.DepositHeldSQL).
+		Scan(&held).Error
+	return held, err
+}
+
+// recordDepositTransaction appends a ledger entry and refreshes the cached
+// Patient.Deposit from the ledger. The patient row should already be locked.
+func recordDepositTransaction(tx *gorm.DB, patient *models.Patient, txType models.DepositTransactionType, amount models.Amount, surgeryID *uint, note string) (models.DepositTransaction, error) {
+	if txType != models.DepositAdjustment && amount <= 0 {
+		return models.DepositTransaction{}, fmt.Errorf("%s amount must be positive, got %s", txType, amount)
+	}
+
+	entry := models.DepositTransaction{
+		PatientID:         patient.ID,
+		SurgeryScheduleID: surgeryID,
+		Type:              txType,
+		Amount:            amount,
+		Currency:          config.Currency(),
+		Note:              note,
+	}
+
+	balance, err := depositLedgerBalance(tx, patient.ID)
+	if err != nil {
+		return entry, err
+	}
+	entry.BalanceAfter = balance + entry.BalanceEffect()
+	if entry.BalanceAfter < 0 {
+		return entry, errInsufficientDeposit
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return entry, err
+	}
+
+	patient.Deposit = entry.BalanceAfter
+	if err := tx.Model(patient).Update("deposit", patient.Deposit).Error; err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+func lockPatient(tx *gorm.DB, patientID interface{}) (models.Patient, error) {
+	var patient models.Patient
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", patientID).
+		First(&patient).Error
+	return patient, err
+}
+
+func TopUpDeposit(c *gin.Context) {
+	postDepositTransaction(c, "TopUpDeposit", models.DepositTopUp)
+}
+
+func AdjustDeposit(c *gin.Context) {
+	postDepositTransaction(c, "AdjustDeposit", models.DepositAdjustment)
+}
+
+func postDepositTransaction(c *gin.Context, handler string, txType models.DepositTransactionType) {
+	patientID := c.Param("id")
+	log.Printf("%s: Request received for patient ID %s", handler, patientID)
+
+	var input models.DepositRequest
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("%s: Invalid request body - %v", handler, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if txType == models.DepositTopUp && input.Amount <= 0 {
+		log.Printf("%s: Rejected non-positive amount %s", handler, input.Amount)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Top-up amount must be positive"})
+		return
+	}
+	if txType == models.DepositAdjustment && input.Note == "" {
+		log.Printf("%s: Adjustment without a note", handler)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Adjustments require a note explaining the change"})
+		return
+	}
+	if _, err := normalizeCurrency(input.Currency); err != nil {
+		log.Printf("%s: %v", handler, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entry models.DepositTransaction
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		patient, err := lockPatient(tx, patientID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("patient not found")
+			}
+			return err
+		}
+
+		entry, err = recordDepositTransaction(tx, &patient, txType, input.Amount, nil, input.Note)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("%s: Transaction failed - %v", handler, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("%s: Recorded %s of %s for patient %s, balance %s", handler, txType, input.Amount, patientID, entry.BalanceAfter)
+	c.JSON(http.StatusCreated, entry)
+}
+
+func GetDepositTransactions(c *gin.Context) {
+	patientID := c.Param("id")
+	log.Printf("GetDepositTransactions: Request received for patient ID %s", patientID)
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, "id = ?", patientID).Error; err != nil {
+		log.Printf("GetDepositTransactions: Patient not found with ID %s", patientID)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found!"})
+		return
+	}
+
+	var transactions []models.DepositTransaction
+	if err := config.DB.Where("patient_id = ?", patient.ID).Order("id").Find(&transactions).Error; err != nil {
+		log.Printf("GetDepositTransactions: Error fetching transactions - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetDepositTransactions: Found %d transactions for patient %s", len(transactions), patientID)
+	c.JSON(http.StatusOK, gin.H{
+		"patient_id":   patient.ID,
+		"balance":      patient.Deposit,
+		"transactions": transactions,
+	})
+}
+
+func ReconcileDeposits(c *gin.Context) {
+	log.Println("ReconcileDeposits: Request received")
+
+	patientDrift := []gin.H{}
+	var patients []models.Patient
+	if err := config.DB.Find(&patients).Error; err != nil {
+		log.Printf("ReconcileDeposits: Error fetching patients - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, patient := range patients {
+		balance, err := depositLedgerBalance(config.DB, patient.ID)
+		if err != nil {
+			log.Printf("ReconcileDeposits: Error computing balance for patient %d - %v", patient.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if balance != patient.Deposit {
+			patientDrift = append(patientDrift, gin.H{
+				"patient_id":     patient.ID,
+				"cached_deposit": patient.Deposit,
+				"ledger_balance": balance,
+				"drift":          patient.Deposit - balance,
+			})
+		}
+	}
+
+	surgeryDrift := []gin.H{}
+	var surgeries []models.SurgerySchedule
+	if err := config.DB.Find(&surgeries).Error; err != nil {
+		log.Printf("ReconcileDeposits: Error fetching surgeries - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, surgery := range surgeries {
+		held, err := depositHeldForSurgery(config.DB, surgery.ID)
+		if err != nil {
+			log.Printf("ReconcileDeposits: Error computing hold for surgery %d - %v", surgery.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// Once a surgery is completed, cancelled or missed its hold must
+		// have been captured or refunded.
+		expected := surgery.DepositDeducted
+		switch surgery.Status {
+		case models.SurgeryStatusCompleted, models.SurgeryStatusCancelled, models.SurgeryStatusNoShow:
+			expected = 0
+		}
+		if held != expected {
+			surgeryDrift = append(surgeryDrift, gin.H{
+				"surgery_id":       surgery.ID,
+				"patient_id":       surgery.PatientID,
+				"status":           surgery.Status,
+				"deposit_deducted": surgery.DepositDeducted,
+				"expected_held":    expected,
+				"ledger_held":      held,
+				"drift":            expected - held,
+			})
+		}
+	}
+
+	log.Printf("ReconcileDeposits: %d patients and %d surgeries drifted from the ledger", len(patientDrift), len(surgeryDrift))
+	c.JSON(http.StatusOK, gin.H{
+		"balanced":      len(patientDrift) == 0 && len(surgeryDrift) == 0,
+		"patient_drift": patientDrift,
+		"surgery_drift": surgeryDrift,
+	})
+}
@@ -3,18 +3,27 @@ package controllers
 import (
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"CRUD-hospital-go/config"
 	"CRUD-hospital-go/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func CreateDoctor(c *gin.Context) {
 	log.Println("CreateDoctor: Request received")
 
-	var input models.Doctor
+	var input struct {
+		Name        string        `json:"name"`
+		ContactNo   string        `json:"contact_no"`
+		Address     string        `json:"address"`
+		SurgeryFee  models.Amount `json:"surgery_fee"`
+		Specialties []string      `json:"specialties"`
+	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		log.Printf("CreateDoctor: Invalid request body - %v", err)
@@ -22,10 +31,30 @@ func CreateDoctor(c *gin.Context) {
 		return
 	}
 
-	config.DB.Create(&input)
+	doctor := models.Doctor{
+		Name:       input.Name,
+		ContactNo:  input.ContactNo,
+		Address:    input.Address,
+		SurgeryFee: input.SurgeryFee,
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		specialties, err := findOrCreateSpecialties(tx, input.Specialties)
+		if err != nil {
+			return err
+		}
+		doctor.Specialties = specialties
+		return tx.Create(&doctor).Error
+	})
+
+	if err != nil {
+		log.Printf("CreateDoctor: Failed to create doctor - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	log.Printf("CreateDoctor: Doctor created successfully with ID %d", input.ID)
-	c.JSON(http.StatusOK, input)
+	log.Printf("CreateDoctor: Doctor created successfully with ID %d", doctor.ID)
+	c.JSON(http.StatusOK, doctor)
 }
 
 func GetAllDoctors(c *gin.Context) {
@@ -33,7 +62,12 @@ func GetAllDoctors(c *gin.Context) {
 
 	var doctors []models.Doctor
 
-	if err := config.DB.Find(&doctors).Error; err != nil {
+	query := config.DB.Preload("Specialties")
+	if specialty := c.Query("specialty"); specialty != "" {
+		query = withSpecialty(query, specialty)
+	}
+
+	if err := query.Find(&doctors).Error; err != nil {
 		log.Printf("GetAllDoctors: Error fetching doctors - %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -48,7 +82,7 @@ func GetDoctorByID(c *gin.Context) {
 
 	var doctor models.Doctor
 
-	if err := config.DB.Where("id = ?", c.Param("id")).First(&doctor).Error; err != nil {
+	if err := config.DB.Preload("Specialties").Where("id = ?", c.Param("id")).First(&doctor).Error; err != nil {
 		log.Printf("GetDoctorByID: Doctor not found with ID %s", c.Param("id"))
 		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found!"})
 		return
@@ -82,7 +116,12 @@ func SearchDoctorByName(c *gin.Context) {
 
 	var doctors []models.Doctor
 
-	if err := config.DB.Where("name LIKE ?", "%"+name+"%").Find(&doctors).Error; err != nil {
+	query := config.DB.Preload("Specialties").Where("name LIKE ?", "%"+name+"%")
+	if specialty := c.Query("specialty"); specialty != "" {
+		query = withSpecialty(query, specialty)
+	}
+
+	if err := query.Find(&doctors).Error; err != nil {
 		log.Printf("SearchDoctorByName: Error searching doctors - %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -105,9 +144,11 @@ func UpdateDoctor(c *gin.Context) {
 	}
 
 	var input struct {
-		Name      *string `json:"name"`
-		ContactNo *string `json:"contact_no"`
-		Address   *string `json:"address"`
+		Name        *string        `json:"name"`
+		ContactNo   *string        `json:"contact_no"`
+		Address     *string        `json:"address"`
+		SurgeryFee  *models.Amount `json:"surgery_fee"`
+		Specialties *[]string      `json:"specialties"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -125,9 +166,37 @@ func UpdateDoctor(c *gin.Context) {
 	if input.Address != nil {
 		doctor.Address = *input.Address
 	}
+	if input.SurgeryFee != nil {
+		if *input.SurgeryFee < 0 {
+			log.Printf("UpdateDoctor: Rejected negative surgery fee %s", *input.SurgeryFee)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Surgery fee cannot be negative"})
+			return
+		}
+		doctor.SurgeryFee = *input.SurgeryFee
+	}
 	doctor.UpdatedAt = time.Now()
 
-	config.DB.Save(&doctor)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&doctor).Error; err != nil {
+			return err
+		}
+		if input.Specialties == nil {
+			return nil
+		}
+		specialties, err := findOrCreateSpecialties(tx, *input.Specialties)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&doctor).Association("Specialties").Replace(specialties)
+	})
+
+	if err != nil {
+		log.Printf("UpdateDoctor: Failed to update doctor - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config.DB.Preload("Specialties").First(&doctor, doctor.ID)
 	log.Printf("UpdateDoctor: Doctor updated successfully with ID %s", id)
 	c.JSON(http.StatusOK, doctor)
 }
@@ -145,28 +214,63 @@ func CheckDoctorAvailability(c *gin.Context) {
 		return
 	}
 
-	date, err := time.Parse("2006-01-02", dateStr)
+	date, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
 	if err != nil {
 		log.Printf("CheckDoctorAvailability: Invalid date format %s", dateStr)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
 		return
 	}
 
-	nextDay := date.Add(24 * time.Hour)
+	minLength := time.Duration(0)
+	if durationStr := c.Query("duration"); durationStr != "" {
+		minutes, err := strconv.Atoi(durationStr)
+		if err != nil || minutes <= 0 {
+			log.Printf("CheckDoctorAvailability: Invalid duration %s", durationStr)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration. Use a positive number of minutes"})
+			return
+		}
+		minLength = time.Duration(minutes) * time.Minute
+	}
+
+	window := models.TimeSlot{Start: date, End: date.AddDate(0, 0, 1)}
+
+	rostered, err := doctorRosteredSlots(config.DB, doctor.ID, window)
+	if err != nil {
+		log.Printf("CheckDoctorAvailability: Error computing roster - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	leave, err := doctorLeaveIn(config.DB, doctor.ID, window)
+	if err != nil {
+		log.Printf("CheckDoctorAvailability: Error fetching leave - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	var existingSurgery models.SurgerySchedule
-	err = config.DB.Where("doctor_id = ? AND scheduled_at >= ? AND scheduled_at < ? AND status NOT IN ?",
-		doctorID, date, nextDay,
-		[]models.SurgeryStatus{models.SurgeryStatusCompleted, models.SurgeryStatusCancelled}).
-		First(&existingSurgery).Error
+	busySlots, err := doctorBusySlots(config.DB, doctor.ID, window)
+	if err != nil {
+		log.Printf("CheckDoctorAvailability: Error fetching surgeries - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	isAvailable := err != nil
+	freeSlots := []models.TimeSlot{}
+	for _, slot := range rostered {
+		freeSlots = append(freeSlots, subtractSlots(slot, busySlots, minLength)...)
+	}
+	isAvailable := len(freeSlots) > 0
 
-	log.Printf("CheckDoctorAvailability: Doctor %s availability on %s: %v", doctorID, dateStr, isAvailable)
+	log.Printf("CheckDoctorAvailability: Doctor %s has %d free slots on %s", doctorID, len(freeSlots), dateStr)
 	c.JSON(http.StatusOK, gin.H{
-		"doctor_id":    doctorID,
-		"doctor_name":  doctor.Name,
-		"date":         dateStr,
-		"is_available": isAvailable,
+		"doctor_id":      doctorID,
+		"doctor_name":    doctor.Name,
+		"date":           dateStr,
+		"is_available":   isAvailable,
+		"buffer_minutes": int(config.DoctorBufferTime().Minutes()),
+		"rostered_slots": rostered,
+		"leave":          leave,
+		"busy_slots":     busySlots,
+		"free_slots":     freeSlots,
 	})
 }
@@ -0,0 +1,551 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errLeaveConflict = errors.New("doctor has bookings during the leave")
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// doctorLeaveIn lists the doctor's leave touching window.
+func doctorLeaveIn(tx *gorm.DB, doctorID uint, window models.TimeSlot) ([]models.DoctorLeave, error) {
+	var leave []models.DoctorLeave
+	err := tx.Where("doctor_id = ? AND start_at < ? AND end_at > ?", doctorID, window.End, window.Start).
+		Order("start_at").
+		Find(&leave).Error
+	return leave, err
+}
+
+// doctorRosteredSlots returns the parts of window the doctor is rostered for:
+// weekly working hours plus shifts, minus leave. Doctors without weekly hours
+// fall back to the hospital working day.
+func doctorRosteredSlots(tx *gorm.DB, doctorID uint, window models.TimeSlot) ([]models.TimeSlot, error) {
+	var hours []models.DoctorWorkingHours
+	if err := tx.Where("doctor_id = ?", doctorID).Find(&hours).Error; err != nil {
+		return nil, err
+	}
+
+	slots := []models.TimeSlot{}
+	for day := startOfDay(window.Start); day.Before(window.End); day = day.AddDate(0, 0, 1) {
+		if len(hours) == 0 {
+			start, end := config.WorkingDay(day)
+			slots = append(slots, models.TimeSlot{Start: start, End: end})
+			continue
+		}
+		for _, block := range hours {
+			if slot, ok := block.SlotOn(day); ok {
+				slots = append(slots, slot)
+			}
+		}
+	}
+
+	var shifts []models.DoctorShift
+	if err := tx.Where("doctor_id = ? AND start_at < ? AND end_at > ?", doctorID, window.End, window.Start).
+		Find(&shifts).Error; err != nil {
+		return nil, err
+	}
+	for _, shift := range shifts {
+		slots = append(slots, shift.Slot())
+	}
+
+	leave, err := doctorLeaveIn(tx, doctorID, window)
+	if err != nil {
+		return nil, err
+	}
+	away := make([]models.TimeSlot, 0, len(leave))
+	for _, l := range leave {
+		away = append(away, l.Slot())
+	}
+
+	rostered := []models.TimeSlot{}
+	for _, slot := range mergeSlots(slots) {
+		if slot.Start.Before(window.Start) {
+			slot.Start = window.Start
+		}
+		if slot.End.After(window.End) {
+			slot.End = window.End
+		}
+		if slot.Duration() <= 0 {
+			continue
+		}
+		rostered = append(rostered, subtractSlots(slot, away, 0)...)
+	}
+	return rostered, nil
+}
+
+// hasDoctorRoster reports whether the doctor has any weekly hours or shifts.
+func hasDoctorRoster(tx *gorm.DB, doctorID uint) (bool, error) {
+	var hours, shifts int64
+	if err := tx.Model(&models.DoctorWorkingHours{}).Where("doctor_id = ?", doctorID).Count(&hours).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Model(&models.DoctorShift{}).Where("doctor_id = ?", doctorID).Count(&shifts).Error; err != nil {
+		return false, err
+	}
+	return hours+shifts > 0, nil
+}
+
+// checkDoctorRostered refuses a slot the doctor is on leave for or that is not
+// entirely covered by their roster. A doctor with no roster at all can be
+// booked at any time; the working day fallback only guides planning.
+func checkDoctorRostered(tx *gorm.DB, doctor models.Doctor, slot models.TimeSlot) error {
+	leave, err := doctorLeaveIn(tx, doctor.ID, slot)
+	if err != nil {
+		return err
+	}
+	if len(leave) > 0 {
+		return fmt.Errorf("%w: doctor %d (%s) is on leave from %s to %s",
+			errDoctorUnavailable, doctor.ID, doctor.Name, leave[0].StartAt.Format(time.RFC3339), leave[0].EndAt.Format(time.RFC3339))
+	}
+
+	configured, err := hasDoctorRoster(tx, doctor.ID)
+	if err != nil {
+		return err
+	}
+	if !configured {
+		return nil
+	}
+
+	rostered, err := doctorRosteredSlots(tx, doctor.ID, slot)
+	if err != nil {
+		return err
+	}
+	for _, r := range rostered {
+		if !r.Start.After(slot.Start) && !r.End.Before(slot.End) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: doctor %d (%s) is not rostered for the whole of %s to %s",
+		errDoctorUnavailable, doctor.ID, doctor.Name, slot.Start.Format(time.RFC3339), slot.End.Format(time.RFC3339))
+}
+
+func findRosterDoctor(c *gin.Context, handler string) (models.Doctor, bool) {
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("%s: Doctor not found with ID %s", handler, c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found!"})
+		return doctor, false
+	}
+	return doctor, true
+}
+
+func GetDoctorRoster(c *gin.Context) {
+	log.Printf("GetDoctorRoster: Request received for doctor ID %s", c.Param("id"))
+
+	doctor, ok := findRosterDoctor(c, "GetDoctorRoster")
+	if !ok {
+		return
+	}
+
+	start, end, err := parseTimeWindow(c.Query("start"), c.Query("end"))
+	if err != nil {
+		log.Printf("GetDoctorRoster: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("end") == "" {
+		end = start.AddDate(0, 0, 7)
+	}
+	window := models.TimeSlot{Start: start, End: end}
+
+	var hours []models.DoctorWorkingHours
+	if err := config.DB.Where("doctor_id = ?", doctor.ID).Order("weekday, start_time").Find(&hours).Error; err != nil {
+		log.Printf("GetDoctorRoster: Error fetching working hours - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var shifts []models.DoctorShift
+	if err := config.DB.Where("doctor_id = ? AND start_at < ? AND end_at > ?", doctor.ID, window.End, window.Start).
+		Order("start_at").
+		Find(&shifts).Error; err != nil {
+		log.Printf("GetDoctorRoster: Error fetching shifts - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	leave, err := doctorLeaveIn(config.DB, doctor.ID, window)
+	if err != nil {
+		log.Printf("GetDoctorRoster: Error fetching leave - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rostered, err := doctorRosteredSlots(config.DB, doctor.ID, window)
+	if err != nil {
+		log.Printf("GetDoctorRoster: Error computing roster - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetDoctorRoster: Doctor %d has %d rostered slots between %s and %s", doctor.ID, len(rostered), start.Format(time.RFC3339), end.Format(time.RFC3339))
+	c.JSON(http.StatusOK, gin.H{
+		"doctor_id":      doctor.ID,
+		"start":          window.Start,
+		"end":            window.End,
+		"working_hours":  hours,
+		"shifts":         shifts,
+		"leave":          leave,
+		"rostered_slots": rostered,
+	})
+}
+
+func CreateDoctorWorkingHours(c *gin.Context) {
+	log.Printf("CreateDoctorWorkingHours: Request received for doctor ID %s", c.Param("id"))
+
+	doctor, ok := findRosterDoctor(c, "CreateDoctorWorkingHours")
+	if !ok {
+		return
+	}
+
+	var input models.DoctorWorkingHours
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateDoctorWorkingHours: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.Validate(); err != nil {
+		log.Printf("CreateDoctorWorkingHours: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.DoctorID = doctor.ID
+	if err := config.DB.Create(&input).Error; err != nil {
+		log.Printf("CreateDoctorWorkingHours: Failed to create working hours - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("CreateDoctorWorkingHours: Added %s %s-%s for doctor %d", *input.Weekday, input.StartTime, input.EndTime, doctor.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func UpdateDoctorWorkingHours(c *gin.Context) {
+	log.Printf("UpdateDoctorWorkingHours: Request received for doctor ID %s, hours ID %s", c.Param("id"), c.Param("hours_id"))
+
+	var hours models.DoctorWorkingHours
+	if err := config.DB.First(&hours, "id = ? AND doctor_id = ?", c.Param("hours_id"), c.Param("id")).Error; err != nil {
+		log.Printf("UpdateDoctorWorkingHours: Working hours %s not found for doctor %s", c.Param("hours_id"), c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Working hours not found!"})
+		return
+	}
+
+	var input struct {
+		Weekday   *time.Weekday `json:"weekday" binding:"omitempty,min=0,max=6"`
+		StartTime *string       `json:"start_time"`
+		EndTime   *string       `json:"end_time"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateDoctorWorkingHours: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Weekday != nil {
+		hours.Weekday = input.Weekday
+	}
+	if input.StartTime != nil {
+		hours.StartTime = *input.StartTime
+	}
+	if input.EndTime != nil {
+		hours.EndTime = *input.EndTime
+	}
+	if err := hours.Validate(); err != nil {
+		log.Printf("UpdateDoctorWorkingHours: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config.DB.Save(&hours)
+
+	log.Printf("UpdateDoctorWorkingHours: Working hours %d updated", hours.ID)
+	c.JSON(http.StatusOK, hours)
+}
+
+func DeleteDoctorWorkingHours(c *gin.Context) {
+	log.Printf("DeleteDoctorWorkingHours: Request received for doctor ID %s, hours ID %s", c.Param("id"), c.Param("hours_id"))
+
+	var hours models.DoctorWorkingHours
+	if err := config.DB.First(&hours, "id = ? AND doctor_id = ?", c.Param("hours_id"), c.Param("id")).Error; err != nil {
+		log.Printf("DeleteDoctorWorkingHours: Working hours %s not found for doctor %s", c.Param("hours_id"), c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Working hours not found!"})
+		return
+	}
+
+	config.DB.Delete(&hours)
+
+	log.Printf("DeleteDoctorWorkingHours: Working hours %d deleted", hours.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Working hours deleted successfully"})
+}
+
+func CreateDoctorShift(c *gin.Context) {
+	log.Printf("CreateDoctorShift: Request received for doctor ID %s", c.Param("id"))
+
+	doctor, ok := findRosterDoctor(c, "CreateDoctorShift")
+	if !ok {
+		return
+	}
+
+	var input models.DoctorShift
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateDoctorShift: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Kind == "" {
+		input.Kind = models.ShiftRegular
+	}
+	if !input.Kind.IsValid() {
+		log.Printf("CreateDoctorShift: Invalid shift kind %q", input.Kind)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid shift kind %q", input.Kind)})
+		return
+	}
+	if !input.EndAt.After(input.StartAt) {
+		log.Printf("CreateDoctorShift: Shift ends before it starts")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at must be after start_at"})
+		return
+	}
+
+	input.DoctorID = doctor.ID
+	if err := config.DB.Create(&input).Error; err != nil {
+		log.Printf("CreateDoctorShift: Failed to create shift - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("CreateDoctorShift: %s shift %d added for doctor %d", input.Kind, input.ID, doctor.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func UpdateDoctorShift(c *gin.Context) {
+	log.Printf("UpdateDoctorShift: Request received for doctor ID %s, shift ID %s", c.Param("id"), c.Param("shift_id"))
+
+	var shift models.DoctorShift
+	if err := config.DB.First(&shift, "id = ? AND doctor_id = ?", c.Param("shift_id"), c.Param("id")).Error; err != nil {
+		log.Printf("UpdateDoctorShift: Shift %s not found for doctor %s", c.Param("shift_id"), c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found!"})
+		return
+	}
+
+	var input struct {
+		Kind    *models.ShiftKind `json:"kind"`
+		StartAt *time.Time        `json:"start_at"`
+		EndAt   *time.Time        `json:"end_at"`
+		Notes   *string           `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateDoctorShift: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Kind != nil {
+		if !input.Kind.IsValid() {
+			log.Printf("UpdateDoctorShift: Invalid shift kind %q", *input.Kind)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid shift kind %q", *input.Kind)})
+			return
+		}
+		shift.Kind = *input.Kind
+	}
+	if input.StartAt != nil {
+		shift.StartAt = *input.StartAt
+	}
+	if input.EndAt != nil {
+		shift.EndAt = *input.EndAt
+	}
+	if input.Notes != nil {
+		shift.Notes = *input.Notes
+	}
+	if !shift.EndAt.After(shift.StartAt) {
+		log.Printf("UpdateDoctorShift: Shift ends before it starts")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at must be after start_at"})
+		return
+	}
+
+	config.DB.Save(&shift)
+
+	log.Printf("UpdateDoctorShift: Shift %d updated", shift.ID)
+	c.JSON(http.StatusOK, shift)
+}
+
+func DeleteDoctorShift(c *gin.Context) {
+	log.Printf("DeleteDoctorShift: Request received for doctor ID %s, shift ID %s", c.Param("id"), c.Param("shift_id"))
+
+	var shift models.DoctorShift
+	if err := config.DB.First(&shift, "id = ? AND doctor_id = ?", c.Param("shift_id"), c.Param("id")).Error; err != nil {
+		log.Printf("DeleteDoctorShift: Shift %s not found for doctor %s", c.Param("shift_id"), c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found!"})
+		return
+	}
+
+	config.DB.Delete(&shift)
+
+	log.Printf("DeleteDoctorShift: Shift %d deleted", shift.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Shift deleted successfully"})
+}
+
+// saveDoctorLeave stores the leave once no active surgery or appointment of
+// the doctor falls inside it. The doctor row is locked like a booking so the
+// two cannot race.
+func saveDoctorLeave(leave *models.DoctorLeave) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var doctor models.Doctor
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doctor, leave.DoctorID).Error; err != nil {
+			return err
+		}
+
+		var booked []models.SurgerySchedule
+		if err := involvingDoctor(overlappingSurgeries(tx, leave.StartAt, leave.EndAt), doctor.ID).
+			Order("scheduled_at").
+			Find(&booked).Error; err != nil {
+			return err
+		}
+		if len(booked) > 0 {
+			ids := make([]uint, 0, len(booked))
+			for _, surgery := range booked {
+				ids = append(ids, surgery.ID)
+			}
+			return fmt.Errorf("%w, reschedule surgeries %v first", errLeaveConflict, ids)
+		}
+
+		var appointments []models.Appointment
+		if err := overlappingAppointments(tx, leave.StartAt, leave.EndAt).
+			Where("doctor_id = ?", doctor.ID).
+			Order("scheduled_at").
+			Find(&appointments).Error; err != nil {
+			return err
+		}
+		if len(appointments) > 0 {
+			ids := make([]uint, 0, len(appointments))
+			for _, appointment := range appointments {
+				ids = append(ids, appointment.ID)
+			}
+			return fmt.Errorf("%w, reschedule appointments %v first", errLeaveConflict, ids)
+		}
+
+		return tx.Save(leave).Error
+	})
+}
+
+func leaveErrorStatus(err error) int {
+	if errors.Is(err, errLeaveConflict) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func CreateDoctorLeave(c *gin.Context) {
+	log.Printf("CreateDoctorLeave: Request received for doctor ID %s", c.Param("id"))
+
+	doctor, ok := findRosterDoctor(c, "CreateDoctorLeave")
+	if !ok {
+		return
+	}
+
+	var input models.DoctorLeave
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateDoctorLeave: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !input.EndAt.After(input.StartAt) {
+		log.Printf("CreateDoctorLeave: Leave ends before it starts")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at must be after start_at"})
+		return
+	}
+
+	input.DoctorID = doctor.ID
+	if err := saveDoctorLeave(&input); err != nil {
+		log.Printf("CreateDoctorLeave: Failed to record leave - %v", err)
+		c.JSON(leaveErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("CreateDoctorLeave: Leave %d recorded for doctor %d", input.ID, doctor.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func UpdateDoctorLeave(c *gin.Context) {
+	log.Printf("UpdateDoctorLeave: Request received for doctor ID %s, leave ID %s", c.Param("id"), c.Param("leave_id"))
+
+	var leave models.DoctorLeave
+	if err := config.DB.First(&leave, "id = ? AND doctor_id = ?", c.Param("leave_id"), c.Param("id")).Error; err != nil {
+		log.Printf("UpdateDoctorLeave: Leave %s not found for doctor %s", c.Param("leave_id"), c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Leave not found!"})
+		return
+	}
+
+	var input struct {
+		StartAt *time.Time `json:"start_at"`
+		EndAt   *time.Time `json:"end_at"`
+		Reason  *string    `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateDoctorLeave: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.StartAt != nil {
+		leave.StartAt = *input.StartAt
+	}
+	if input.EndAt != nil {
+		leave.EndAt = *input.EndAt
+	}
+	if input.Reason != nil {
+		leave.Reason = *input.Reason
+	}
+	if !leave.EndAt.After(leave.StartAt) {
+		log.Printf("UpdateDoctorLeave: Leave ends before it starts")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at must be after start_at"})
+		return
+	}
+
+	if err := saveDoctorLeave(&leave); err != nil {
+		log.Printf("UpdateDoctorLeave: Failed to update leave - %v", err)
+		c.JSON(leaveErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("UpdateDoctorLeave: Leave %d updated", leave.ID)
+	c.JSON(http.StatusOK, leave)
+}
+
+func DeleteDoctorLeave(c *gin.Context) {
+	log.Printf("DeleteDoctorLeave: Request received for doctor ID %s, leave ID %s", c.Param("id"), c.Param("leave_id"))
+
+	var leave models.DoctorLeave
+	if err := config.DB.First(&leave, "id = ? AND doctor_id = ?", c.Param("leave_id"), c.Param("id")).Error; err != nil {
+		log.Printf("DeleteDoctorLeave: Leave %s not found for doctor %s", c.Param("leave_id"), c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Leave not found!"})
+		return
+	}
+
+	config.DB.Delete(&leave)
+
+	log.Printf("DeleteDoctorLeave: Leave %d deleted", leave.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Leave deleted successfully"})
+}
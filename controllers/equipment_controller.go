@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// upcomingEquipmentBookings scopes bookings of active surgeries that have not
+// ended yet.
+func upcomingEquipmentBookings(tx *gorm.DB) *gorm.DB {
+	return tx.Where("end_at > ? AND surgery_schedule_id IN (?)", time.Now(),
+		tx.Session(&gorm.Session{NewDB: true}).Model(&models.SurgerySchedule{}).
+			Select("id").
+			Where("status NOT IN ?", models.InactiveSurgeryStatuses))
+}
+
+func CreateEquipment(c *gin.Context) {
+	log.Println("CreateEquipment: Request received")
+
+	var input models.Equipment
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateEquipment: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.OperatingTheaterID != nil {
+		var ot models.OperatingTheater
+		if err := config.DB.First(&ot, "id = ?", *input.OperatingTheaterID).Error; err != nil {
+			log.Printf("CreateEquipment: Operating Theater not found with ID %d", *input.OperatingTheaterID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Operating Theater not found!"})
+			return
+		}
+	} else if !input.Movable {
+		log.Printf("CreateEquipment: Installed equipment without a theater")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Installed equipment needs an operating_theater_id, or set movable"})
+		return
+	}
+
+	config.DB.Create(&input)
+
+	log.Printf("CreateEquipment: Equipment %s created with ID %d", input.Name, input.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func GetAllEquipment(c *gin.Context) {
+	log.Println("GetAllEquipment: Request received")
+
+	query := config.DB.Order("name, id")
+	if movable := c.Query("movable"); movable != "" {
+		value, err := strconv.ParseBool(movable)
+		if err != nil {
+			log.Printf("GetAllEquipment: Invalid movable filter %s", movable)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movable filter. Use true or false"})
+			return
+		}
+		query = query.Where("movable = ?", value)
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	if otID := c.Query("operating_theater_id"); otID != "" {
+		query = query.Where("operating_theater_id = ?", otID)
+	}
+
+	var equipment []models.Equipment
+
+	if err := query.Find(&equipment).Error; err != nil {
+		log.Printf("GetAllEquipment: Error fetching equipment - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetAllEquipment: Found %d items", len(equipment))
+	c.JSON(http.StatusOK, equipment)
+}
+
+func UpdateEquipment(c *gin.Context) {
+	log.Printf("UpdateEquipment: Request received for ID %s", c.Param("id"))
+
+	var equipment models.Equipment
+	id := c.Param("id")
+
+	if err := config.DB.First(&equipment, "id = ?", id).Error; err != nil {
+		log.Printf("UpdateEquipment: Equipment not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Equipment not found!"})
+		return
+	}
+
+	var input struct {
+		Name               *string `json:"name"`
+		Movable            *bool   `json:"movable"`
+		OperatingTheaterID *uint   `json:"operating_theater_id"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateEquipment: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		equipment.Name = *input.Name
+	}
+	if input.Movable != nil {
+		equipment.Movable = *input.Movable
+	}
+	if input.OperatingTheaterID != nil {
+		var ot models.OperatingTheater
+		if err := config.DB.First(&ot, "id = ?", *input.OperatingTheaterID).Error; err != nil {
+			log.Printf("UpdateEquipment: Operating Theater not found with ID %d", *input.OperatingTheaterID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Operating Theater not found!"})
+			return
+		}
+		equipment.OperatingTheaterID = &ot.ID
+	}
+	if !equipment.Movable && equipment.OperatingTheaterID == nil {
+		log.Printf("UpdateEquipment: Installed equipment without a theater")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Installed equipment needs an operating_theater_id"})
+		return
+	}
+
+	if input.Movable != nil && !*input.Movable {
+		var booked int64
+		if err := upcomingEquipmentBookings(config.DB.Model(&models.EquipmentBooking{})).
+			Where("equipment_id = ?", equipment.ID).
+			Count(&booked).Error; err != nil {
+			log.Printf("UpdateEquipment: Error checking bookings - %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if booked > 0 {
+			log.Printf("UpdateEquipment: Equipment %d still has %d bookings", equipment.ID, booked)
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("equipment %d has %d upcoming bookings", equipment.ID, booked)})
+			return
+		}
+	}
+
+	config.DB.Save(&equipment)
+
+	log.Printf("UpdateEquipment: Equipment updated successfully with ID %s", id)
+	c.JSON(http.StatusOK, equipment)
+}
+
+func DeleteEquipment(c *gin.Context) {
+	log.Printf("DeleteEquipment: Request received for ID %s", c.Param("id"))
+
+	var equipment models.Equipment
+	id := c.Param("id")
+
+	if err := config.DB.First(&equipment, "id = ?", id).Error; err != nil {
+		log.Printf("DeleteEquipment: Equipment not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Equipment not found!"})
+		return
+	}
+
+	var booked int64
+	if err := upcomingEquipmentBookings(config.DB.Model(&models.EquipmentBooking{})).
+		Where("equipment_id = ?", equipment.ID).
+		Count(&booked).Error; err != nil {
+		log.Printf("DeleteEquipment: Error checking bookings - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if booked > 0 {
+		log.Printf("DeleteEquipment: Equipment %d still has %d bookings", equipment.ID, booked)
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("equipment %d has %d upcoming bookings", equipment.ID, booked)})
+		return
+	}
+
+	config.DB.Delete(&equipment)
+
+	log.Printf("DeleteEquipment: Equipment deleted successfully with ID %s", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Equipment deleted successfully"})
+}
+
+func GetEquipmentBookings(c *gin.Context) {
+	log.Printf("GetEquipmentBookings: Request received for equipment ID %s", c.Param("id"))
+
+	var bookings []models.EquipmentBooking
+
+	if err := upcomingEquipmentBookings(config.DB).
+		Where("equipment_id = ?", c.Param("id")).
+		Order("start_at").
+		Find(&bookings).Error; err != nil {
+		log.Printf("GetEquipmentBookings: Error fetching bookings - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetEquipmentBookings: Found %d upcoming bookings for equipment %s", len(bookings), c.Param("id"))
+	c.JSON(http.StatusOK, bookings)
+}
@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// generateSurgeryInvoice bills a completed surgery and settles it against the
+// deposit held for it: the held money is captured up to the invoice total and
+// anything left over is refunded to the patient's available deposit.
+func generateSurgeryInvoice(tx *gorm.DB, surgery models.SurgerySchedule) (models.Invoice, error) {
+	invoice := models.Invoice{
+		PatientID:         surgery.PatientID,
+		SurgeryScheduleID: surgery.ID,
+		Currency:          config.Currency(),
+	}
+
+	var surgeryType models.SurgeryType
+	if err := tx.Where("code = ?", surgery.SurgeryType).First(&surgeryType).Error; err == nil {
+		invoice.Lines = append(invoice.Lines, models.InvoiceLine{
+			Kind:        models.InvoiceLineBasePrice,
+			Description: fmt.Sprintf("%s base price", surgery.SurgeryType),
+			Quantity:    1,
+			UnitPrice:   surgeryType.BasePrice,
+			Amount:      surgeryType.BasePrice,
+		})
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("generateSurgeryInvoice: No price for surgery type %q, billing without a base price", surgery.SurgeryType)
+	} else {
+		return invoice, err
+	}
+
+	var ot models.OperatingTheater
+	if err := tx.First(&ot, surgery.OperatingTheaterID).Error; err != nil {
+		return invoice, err
+	}
+	if surgery.ActualStartAt != nil && surgery.ActualEndAt != nil {
+		// Whole hours are billed at the hourly rate and the minutes left over
+		// as one prorated line, so quantity times unit price is the amount.
+		minutes := int(math.Ceil(surgery.ActualEndAt.Sub(*surgery.ActualStartAt).Minutes()))
+		if hours := minutes / 60; hours > 0 {
+			invoice.Lines = append(invoice.Lines, models.InvoiceLine{
+				Kind:        models.InvoiceLineTheater,
+				Description: fmt.Sprintf("%s time, %d hours at %s per hour", ot.Name, hours, ot.HourlyRate),
+				Quantity:    hours,
+				UnitPrice:   ot.HourlyRate,
+				Amount:      ot.HourlyRate * models.Amount(hours),
+			})
+		}
+		if rest := minutes % 60; rest > 0 {
+			amount := models.Amount((int64(ot.HourlyRate)*int64(rest) + 30) / 60)
+			invoice.Lines = append(invoice.Lines, models.InvoiceLine{
+				Kind:        models.InvoiceLineTheater,
+				Description: fmt.Sprintf("%s time, %d minutes at %s per hour", ot.Name, rest, ot.HourlyRate),
+				Quantity:    1,
+				UnitPrice:   amount,
+				Amount:      amount,
+			})
+		}
+	}
+
+	var doctor models.Doctor
+	if err := tx.First(&doctor, surgery.DoctorID).Error; err != nil {
+		return invoice, err
+	}
+	invoice.Lines = append(invoice.Lines, models.InvoiceLine{
+		Kind:        models.InvoiceLineDoctorFee,
+		Description: fmt.Sprintf("Surgeon fee, %s", doctor.Name),
+		Quantity:    1,
+		UnitPrice:   doctor.SurgeryFee,
+		Amount:      doctor.SurgeryFee,
+	})
+
+	var team []models.SurgicalTeamMember
+	if err := tx.Preload("Doctor").Where("surgery_schedule_id = ?", surgery.ID).Find(&team).Error; err != nil {
+		return invoice, err
+	}
+	for _, member := range team {
+		invoice.Lines = append(invoice.Lines, models.InvoiceLine{
+			Kind:        models.InvoiceLineDoctorFee,
+			Description: fmt.Sprintf("%s fee, %s", member.Role, member.Doctor.Name),
+			Quantity:    1,
+			UnitPrice:   member.Doctor.SurgeryFee,
+			Amount:      member.Doctor.SurgeryFee,
+		})
+	}
+
+	var consumables []models.SurgeryConsumable
+	if err := tx.Where("surgery_schedule_id = ?", surgery.ID).Find(&consumables).Error; err != nil {
+		return invoice, err
+	}
+	for _, item := range consumables {
+		invoice.Lines = append(invoice.Lines, models.InvoiceLine{
+			Kind:        models.InvoiceLineConsumable,
+			Description: item.Name,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Amount:      item.UnitPrice * models.Amount(item.Quantity),
+		})
+	}
+
+	for _, line := range invoice.Lines {
+		invoice.Subtotal += line.Amount
+	}
+
+	held, err := depositHeldForSurgery(tx, surgery.ID)
+	if err != nil {
+		return invoice, err
+	}
+	invoice.DepositApplied = min(held, invoice.Subtotal)
+	invoice.Settle()
+
+	if err := tx.Create(&invoice).Error; err != nil {
+		return invoice, err
+	}
+
+	if held > 0 {
+		patient, err := lockPatient(tx, surgery.PatientID)
+		if err != nil {
+			return invoice, err
+		}
+		if invoice.DepositApplied > 0 {
+			note := fmt.Sprintf("Applied to invoice %d", invoice.ID)
+			if _, err := recordDepositTransaction(tx, &patient, models.DepositCapture, invoice.DepositApplied, &surgery.ID, note); err != nil {
+				return invoice, err
+			}
+		}
+		if remainder := held - invoice.DepositApplied; remainder > 0 {
+			note := fmt.Sprintf("Unused deposit after invoice %d", invoice.ID)
+			if _, err := recordDepositTransaction(tx, &patient, models.DepositRefund, remainder, &surgery.ID, note); err != nil {
+				return invoice, err
+			}
+		}
+	}
+
+	return invoice, nil
+}
+
+func GetInvoiceByID(c *gin.Context) {
+	log.Printf("GetInvoiceByID: Request received for ID %s", c.Param("id"))
+
+	var invoice models.Invoice
+
+	if err := config.DB.Preload("Lines").Preload("Payments").
+		Where("id = ?", c.Param("id")).
+		First(&invoice).Error; err != nil {
+		log.Printf("GetInvoiceByID: Invoice not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found!"})
+		return
+	}
+
+	log.Printf("GetInvoiceByID: Invoice found with ID %d", invoice.ID)
+	c.JSON(http.StatusOK, invoice)
+}
+
+func GetInvoiceBySurgery(c *gin.Context) {
+	log.Printf("GetInvoiceBySurgery: Request received for surgery_id %s", c.Param("surgery_id"))
+
+	var invoice models.Invoice
+
+	if err := config.DB.Preload("Lines").Preload("Payments").
+		Where("surgery_schedule_id = ?", c.Param("surgery_id")).
+		First(&invoice).Error; err != nil {
+		log.Printf("GetInvoiceBySurgery: Invoice not found for surgery_id %s", c.Param("surgery_id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found!"})
+		return
+	}
+
+	log.Printf("GetInvoiceBySurgery: Invoice %d found for surgery_id %s", invoice.ID, c.Param("surgery_id"))
+	c.JSON(http.StatusOK, invoice)
+}
+
+func GetInvoicesByPatient(c *gin.Context) {
+	log.Printf("GetInvoicesByPatient: Request received for patient_id %s", c.Param("patient_id"))
+
+	var invoices []models.Invoice
+
+	if err := config.DB.Preload("Lines").Preload("Payments").
+		Where("patient_id = ?", c.Param("patient_id")).
+		Find(&invoices).Error; err != nil {
+		log.Printf("GetInvoicesByPatient: Error fetching invoices - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetInvoicesByPatient: Found %d invoices for patient_id %s", len(invoices), c.Param("patient_id"))
+	c.JSON(http.StatusOK, invoices)
+}
+
+func GetPatientBalance(c *gin.Context) {
+	log.Printf("GetPatientBalance: Request received for patient ID %s", c.Param("id"))
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GetPatientBalance: Patient not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found!"})
+		return
+	}
+
+	var outstanding models.Amount
+	if err := config.DB.Model(&models.Invoice{}).
+		Where("patient_id = ? AND status = ?", patient.ID, models.InvoiceStatusOpen).
+		Select("COALESCE(SUM(outstanding), 0)").
+		Scan(&outstanding).Error; err != nil {
+		log.Printf("GetPatientBalance: Error summing invoices - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetPatientBalance: Patient %d owes %s with %s deposit available", patient.ID, outstanding, patient.Deposit)
+	c.JSON(http.StatusOK, gin.H{
+		"patient_id":        patient.ID,
+		"currency":          config.Currency(),
+		"outstanding":       outstanding,
+		"deposit_available": patient.Deposit,
+	})
+}
+
+func RecordPayment(c *gin.Context) {
+	invoiceID := c.Param("id")
+	log.Printf("RecordPayment: Request received for invoice ID %s", invoiceID)
+
+	var payment models.Payment
+
+	if err := c.ShouldBindJSON(&payment); err != nil {
+		log.Printf("RecordPayment: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, err := normalizeCurrency(payment.Currency)
+	if err != nil {
+		log.Printf("RecordPayment: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payment.Currency = currency
+
+	var invoice models.Invoice
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", invoiceID).
+			First(&invoice).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invoice not found")
+			}
+			return err
+		}
+
+		if payment.Amount > invoice.Outstanding {
+			log.Printf("RecordPayment: Payment %s exceeds outstanding %s on invoice %d", payment.Amount, invoice.Outstanding, invoice.ID)
+			return fmt.Errorf("payment of %s exceeds the outstanding balance of %s", payment.Amount, invoice.Outstanding)
+		}
+
+		payment.InvoiceID = invoice.ID
+		payment.PatientID = invoice.PatientID
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+
+		invoice.AmountPaid += payment.Amount
+		invoice.Settle()
+		return tx.Omit(clause.Associations).Save(&invoice).Error
+	})
+
+	if err != nil {
+		log.Printf("RecordPayment: Transaction failed - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config.DB.Preload("Lines").Preload("Payments").First(&invoice, invoice.ID)
+
+	log.Printf("RecordPayment: Payment %d of %s recorded on invoice %d", payment.ID, payment.Amount, invoice.ID)
+	c.JSON(http.StatusCreated, invoice)
+}
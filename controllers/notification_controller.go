@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// notifySurgeryDoctors leaves a message for the lead surgeon and every team
+// member of the surgery.
+func notifySurgeryDoctors(tx *gorm.DB, surgery models.SurgerySchedule, message string) error {
+	doctorIDs := []uint{surgery.DoctorID}
+	var team []models.SurgicalTeamMember
+	if err := tx.Where("surgery_schedule_id = ?", surgery.ID).Find(&team).Error; err != nil {
+		return err
+	}
+	for _, member := range team {
+		doctorIDs = append(doctorIDs, member.DoctorID)
+	}
+
+	for _, doctorID := range doctorIDs {
+		notification := models.Notification{
+			DoctorID:          doctorID,
+			SurgeryScheduleID: &surgery.ID,
+			Message:           message,
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return err
+		}
+	}
+	log.Printf("notifySurgeryDoctors: Notified %d doctors about surgery %d", len(doctorIDs), surgery.ID)
+	return nil
+}
+
+func GetDoctorNotifications(c *gin.Context) {
+	log.Printf("GetDoctorNotifications: Request received for doctor ID %s", c.Param("id"))
+
+	query := config.DB.Where("doctor_id = ?", c.Param("id"))
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+
+	if err := query.Order("id DESC").Find(&notifications).Error; err != nil {
+		log.Printf("GetDoctorNotifications: Error fetching notifications - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetDoctorNotifications: Found %d notifications for doctor %s", len(notifications), c.Param("id"))
+	c.JSON(http.StatusOK, notifications)
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	log.Printf("MarkNotificationRead: Request received for ID %s", c.Param("id"))
+
+	var notification models.Notification
+
+	if err := config.DB.First(&notification, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("MarkNotificationRead: Notification not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found!"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		config.DB.Save(&notification)
+	}
+
+	log.Printf("MarkNotificationRead: Notification %d marked as read", notification.ID)
+	c.JSON(http.StatusOK, notification)
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -9,8 +11,64 @@ import (
 	"CRUD-hospital-go/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var errTheaterInUse = errors.New("operating theater is in use")
+
+// theaterSurgeryIDs lists the surgeries in the theater matched by query.
+func theaterSurgeryIDs(query *gorm.DB, otID uint) ([]uint, error) {
+	ids := []uint{}
+	err := query.Model(&models.SurgerySchedule{}).
+		Where("operating_theater_id = ?", otID).
+		Order("scheduled_at").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// validateTheaterStatusChange keeps manual status changes from orphaning
+// surgeries: Occupied follows a running surgery, a theater cannot be freed
+// while one runs, and it cannot go into maintenance with bookings ahead.
+func validateTheaterStatusChange(tx *gorm.DB, ot models.OperatingTheater, next models.OTStatus) error {
+	if !next.IsValid() {
+		return fmt.Errorf("invalid status %q", next)
+	}
+	if next == models.OTStatusOccupied {
+		return fmt.Errorf("%w: %s is set by starting a surgery", errTheaterInUse, models.OTStatusOccupied)
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ot, ot.ID).Error; err != nil {
+		return err
+	}
+
+	running, err := theaterSurgeryIDs(tx.Where("status = ?", models.SurgeryStatusInProgress), ot.ID)
+	if err != nil {
+		return err
+	}
+	if len(running) > 0 {
+		return fmt.Errorf("%w: surgeries %v are in progress, complete them first", errTheaterInUse, running)
+	}
+
+	if next == models.OTStatusMaintenance {
+		upcoming, err := theaterSurgeryIDs(tx.Where("status = ? AND scheduled_end > ?", models.SurgeryStatusScheduled, time.Now()), ot.ID)
+		if err != nil {
+			return err
+		}
+		if len(upcoming) > 0 {
+			return fmt.Errorf("%w: surgeries %v are still booked, reschedule them or plan a maintenance window instead", errTheaterInUse, upcoming)
+		}
+	}
+	return nil
+}
+
+func theaterErrorStatus(err error) int {
+	if errors.Is(err, errTheaterInUse) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
 func CreateOperatingTheater(c *gin.Context) {
 	log.Println("CreateOperatingTheater: Request received")
 
@@ -25,6 +83,16 @@ func CreateOperatingTheater(c *gin.Context) {
 	if input.Status == "" {
 		input.Status = models.OTStatusAvailable
 	}
+	if !input.Status.IsValid() {
+		log.Printf("CreateOperatingTheater: Invalid status %q", input.Status)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid status %q", input.Status)})
+		return
+	}
+	if input.TurnoverMinutes != nil && *input.TurnoverMinutes < 0 {
+		log.Printf("CreateOperatingTheater: Rejected negative turnover %d", *input.TurnoverMinutes)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Turnover minutes cannot be negative"})
+		return
+	}
 
 	config.DB.Create(&input)
 	log.Printf("CreateOperatingTheater: Operating Theater created successfully with ID %d", input.ID)
@@ -36,7 +104,7 @@ func GetOperatingTheaterByID(c *gin.Context) {
 
 	var ot models.OperatingTheater
 
-	if err := config.DB.Where("id = ?", c.Param("id")).First(&ot).Error; err != nil {
+	if err := config.DB.Preload("Equipment").Where("id = ?", c.Param("id")).First(&ot).Error; err != nil {
 		log.Printf("GetOperatingTheaterByID: Operating Theater not found with ID %s", c.Param("id"))
 		c.JSON(http.StatusNotFound, gin.H{"error": "Operating Theater not found!"})
 		return
@@ -51,7 +119,7 @@ func GetAllOperatingTheaters(c *gin.Context) {
 
 	var ots []models.OperatingTheater
 
-	if err := config.DB.Find(&ots).Error; err != nil {
+	if err := config.DB.Preload("Equipment").Find(&ots).Error; err != nil {
 		log.Printf("GetAllOperatingTheaters: Error fetching Operating Theaters - %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -62,16 +130,36 @@ func GetAllOperatingTheaters(c *gin.Context) {
 }
 
 func GetAvailableOperatingTheaters(c *gin.Context) {
-	log.Println("GetAvailableOperatingTheaters: Request received")
+	log.Printf("GetAvailableOperatingTheaters: Request received for window %s - %s", c.Query("start"), c.Query("end"))
 
-	var ots []models.OperatingTheater
+	start, end, err := parseTimeWindow(c.Query("start"), c.Query("end"))
+	if err != nil {
+		log.Printf("GetAvailableOperatingTheaters: Invalid time window - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var theaters []models.OperatingTheater
 
-	if err := config.DB.Where("status = ?", models.OTStatusAvailable).Find(&ots).Error; err != nil {
-		log.Printf("GetAvailableOperatingTheaters: Error fetching available Operating Theaters - %v", err)
+	if err := config.DB.Preload("Equipment").Where("status <> ?", models.OTStatusMaintenance).Find(&theaters).Error; err != nil {
+		log.Printf("GetAvailableOperatingTheaters: Error fetching Operating Theaters - %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	ots := []models.OperatingTheater{}
+	for _, ot := range theaters {
+		free, err := isOperatingTheaterFree(config.DB, ot, models.TimeSlot{Start: start, End: end}, 0)
+		if err != nil {
+			log.Printf("GetAvailableOperatingTheaters: Error checking bookings for OT %d - %v", ot.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if free {
+			ots = append(ots, ot)
+		}
+	}
+
 	log.Printf("GetAvailableOperatingTheaters: Found %d available Operating Theaters", len(ots))
 	c.JSON(http.StatusOK, ots)
 }
@@ -89,10 +177,14 @@ func UpdateOperatingTheater(c *gin.Context) {
 	}
 
 	var input struct {
-		Name     *string          `json:"name"`
-		Floor    *int             `json:"floor"`
-		Status   *models.OTStatus `json:"status"`
-		Capacity *int             `json:"capacity"`
+		Name       *string          `json:"name"`
+		Floor      *int             `json:"floor"`
+		Status     *models.OTStatus `json:"status"`
+		Capacity   *int             `json:"capacity"`
+		Class      *string          `json:"class"`
+		HourlyRate *models.Amount   `json:"hourly_rate"`
+		// TurnoverMinutes of -1 clears the override.
+		TurnoverMinutes *int `json:"turnover_minutes"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -101,21 +193,62 @@ func UpdateOperatingTheater(c *gin.Context) {
 		return
 	}
 
-	if input.Name != nil {
-		ot.Name = *input.Name
-	}
-	if input.Floor != nil {
-		ot.Floor = *input.Floor
+	if input.HourlyRate != nil && *input.HourlyRate < 0 {
+		log.Printf("UpdateOperatingTheater: Rejected negative hourly rate %s", *input.HourlyRate)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hourly rate cannot be negative"})
+		return
 	}
-	if input.Status != nil {
-		ot.Status = *input.Status
+	if input.TurnoverMinutes != nil && *input.TurnoverMinutes < -1 {
+		log.Printf("UpdateOperatingTheater: Rejected negative turnover %d", *input.TurnoverMinutes)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Turnover minutes cannot be negative"})
+		return
 	}
-	if input.Capacity != nil {
-		ot.Capacity = *input.Capacity
+
+	// The theater is read again under lock so that a concurrent status
+	// change, e.g. a surgery starting, is not overwritten by this update.
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ot, ot.ID).Error; err != nil {
+			return err
+		}
+
+		if input.Name != nil {
+			ot.Name = *input.Name
+		}
+		if input.Floor != nil {
+			ot.Floor = *input.Floor
+		}
+		if input.Capacity != nil {
+			ot.Capacity = *input.Capacity
+		}
+		if input.Class != nil {
+			ot.Class = *input.Class
+		}
+		if input.HourlyRate != nil {
+			ot.HourlyRate = *input.HourlyRate
+		}
+		if input.TurnoverMinutes != nil {
+			if *input.TurnoverMinutes == -1 {
+				ot.TurnoverMinutes = nil
+			} else {
+				ot.TurnoverMinutes = input.TurnoverMinutes
+			}
+		}
+		if input.Status != nil && *input.Status != ot.Status {
+			if err := validateTheaterStatusChange(tx, ot, *input.Status); err != nil {
+				return err
+			}
+			ot.Status = *input.Status
+		}
+		ot.UpdatedAt = time.Now()
+		return tx.Omit(clause.Associations).Save(&ot).Error
+	})
+
+	if err != nil {
+		log.Printf("UpdateOperatingTheater: Update rejected - %v", err)
+		c.JSON(theaterErrorStatus(err), gin.H{"error": err.Error()})
+		return
 	}
-	ot.UpdatedAt = time.Now()
 
-	config.DB.Save(&ot)
 	log.Printf("UpdateOperatingTheater: Operating Theater updated successfully with ID %s", id)
 	c.JSON(http.StatusOK, ot)
 }
@@ -132,7 +265,209 @@ func DeleteOperatingTheater(c *gin.Context) {
 		return
 	}
 
+	active, err := theaterSurgeryIDs(config.DB.Where("status IN ?", []models.SurgeryStatus{
+		models.SurgeryStatusScheduled, models.SurgeryStatusInProgress, models.SurgeryStatusPostponed,
+	}), ot.ID)
+	if err != nil {
+		log.Printf("DeleteOperatingTheater: Error checking surgeries - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(active) > 0 {
+		log.Printf("DeleteOperatingTheater: OT %d still has surgeries %v", ot.ID, active)
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("operating theater %d still has surgeries %v, move or cancel them first", ot.ID, active)})
+		return
+	}
+
 	config.DB.Delete(&ot)
 	log.Printf("DeleteOperatingTheater: Operating Theater deleted successfully with ID %s", id)
 	c.JSON(http.StatusOK, gin.H{"message": "Operating Theater deleted successfully"})
 }
+
+func AddOperatingTheaterEquipment(c *gin.Context) {
+	log.Printf("AddOperatingTheaterEquipment: Request received for OT ID %s", c.Param("id"))
+
+	var ot models.OperatingTheater
+	id := c.Param("id")
+
+	if err := config.DB.First(&ot, "id = ?", id).Error; err != nil {
+		log.Printf("AddOperatingTheaterEquipment: Operating Theater not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Operating Theater not found!"})
+		return
+	}
+
+	var input models.Equipment
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("AddOperatingTheaterEquipment: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.OperatingTheaterID = &ot.ID
+	config.DB.Create(&input)
+
+	log.Printf("AddOperatingTheaterEquipment: Equipment %d added to OT %d", input.ID, ot.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+// surgeriesNeedingEquipment lists the Scheduled surgeries in the theater that
+// rely on the installed unit: taking it out would leave their required
+// equipment missing. Movable units carry their own bookings and are skipped.
+func surgeriesNeedingEquipment(tx *gorm.DB, ot models.OperatingTheater, equipment models.Equipment) ([]uint, error) {
+	ids := []uint{}
+	if equipment.Movable {
+		return ids, nil
+	}
+
+	remaining := ot
+	remaining.Equipment = nil
+	for _, item := range ot.Equipment {
+		if item.ID != equipment.ID {
+			remaining.Equipment = append(remaining.Equipment, item)
+		}
+	}
+
+	var surgeries []models.SurgerySchedule
+	if err := tx.Where("operating_theater_id = ? AND status = ?", ot.ID, models.SurgeryStatusScheduled).
+		Order("scheduled_at").
+		Find(&surgeries).Error; err != nil {
+		return nil, err
+	}
+	for _, surgery := range surgeries {
+		if len(missingEquipment(remaining, surgery.RequiredEquipment)) > len(missingEquipment(ot, surgery.RequiredEquipment)) {
+			ids = append(ids, surgery.ID)
+		}
+	}
+	return ids, nil
+}
+
+func RemoveOperatingTheaterEquipment(c *gin.Context) {
+	log.Printf("RemoveOperatingTheaterEquipment: Request received for OT ID %s, equipment ID %s", c.Param("id"), c.Param("equipment_id"))
+
+	var equipment models.Equipment
+	var needed []uint
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var ot models.OperatingTheater
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Equipment").First(&ot, "id = ?", c.Param("id")).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&equipment, "id = ? AND operating_theater_id = ?", c.Param("equipment_id"), ot.ID).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if needed, err = surgeriesNeedingEquipment(tx, ot, equipment); err != nil {
+			return err
+		}
+		if len(needed) > 0 {
+			return errTheaterInUse
+		}
+		return tx.Delete(&equipment).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("RemoveOperatingTheaterEquipment: Equipment %s not found in OT %s", c.Param("equipment_id"), c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Equipment not found!"})
+		return
+	}
+	if errors.Is(err, errTheaterInUse) {
+		log.Printf("RemoveOperatingTheaterEquipment: Equipment %d still needed by surgeries %v", equipment.ID, needed)
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("equipment %d is required by scheduled surgeries %v, move or cancel them first", equipment.ID, needed)})
+		return
+	}
+	if err != nil {
+		log.Printf("RemoveOperatingTheaterEquipment: Error removing equipment - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("RemoveOperatingTheaterEquipment: Equipment %d removed from OT %s", equipment.ID, c.Param("id"))
+	c.JSON(http.StatusOK, gin.H{"message": "Equipment removed successfully"})
+}
+
+func GetOperatingTheaterMaintenance(c *gin.Context) {
+	log.Printf("GetOperatingTheaterMaintenance: Request received for OT ID %s", c.Param("id"))
+
+	var windows []models.OTMaintenanceWindow
+
+	if err := config.DB.Where("operating_theater_id = ? AND end_at > ?", c.Param("id"), time.Now()).
+		Order("start_at").
+		Find(&windows).Error; err != nil {
+		log.Printf("GetOperatingTheaterMaintenance: Error fetching maintenance windows - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetOperatingTheaterMaintenance: Found %d upcoming windows for OT %s", len(windows), c.Param("id"))
+	c.JSON(http.StatusOK, windows)
+}
+
+func ScheduleOperatingTheaterMaintenance(c *gin.Context) {
+	log.Printf("ScheduleOperatingTheaterMaintenance: Request received for OT ID %s", c.Param("id"))
+
+	var input models.OTMaintenanceWindow
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("ScheduleOperatingTheaterMaintenance: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !input.EndAt.After(input.StartAt) {
+		log.Printf("ScheduleOperatingTheaterMaintenance: Window ends before it starts")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at must be after start_at"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var ot models.OperatingTheater
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", c.Param("id")).
+			First(&ot).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("operating theater not found")
+			}
+			return err
+		}
+
+		// Turnover is kept on both sides of the window, see
+		// overlappingMaintenance.
+		turnover := ot.Turnover(config.OTTurnoverTime())
+		booked, err := theaterSurgeryIDs(overlappingSurgeries(tx, input.StartAt.Add(-turnover), input.EndAt.Add(turnover)), ot.ID)
+		if err != nil {
+			return err
+		}
+		if len(booked) > 0 {
+			return fmt.Errorf("%w: surgeries %v are booked during the window, reschedule them first", errTheaterInUse, booked)
+		}
+
+		input.OperatingTheaterID = ot.ID
+		return tx.Create(&input).Error
+	})
+
+	if err != nil {
+		log.Printf("ScheduleOperatingTheaterMaintenance: Transaction failed - %v", err)
+		c.JSON(theaterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("ScheduleOperatingTheaterMaintenance: Window %d planned for OT %d", input.ID, input.OperatingTheaterID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func CancelOperatingTheaterMaintenance(c *gin.Context) {
+	log.Printf("CancelOperatingTheaterMaintenance: Request received for OT ID %s, window ID %s", c.Param("id"), c.Param("window_id"))
+
+	var window models.OTMaintenanceWindow
+
+	if err := config.DB.First(&window, "id = ? AND operating_theater_id = ?", c.Param("window_id"), c.Param("id")).Error; err != nil {
+		log.Printf("CancelOperatingTheaterMaintenance: Window %s not found for OT %s", c.Param("window_id"), c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found!"})
+		return
+	}
+
+	config.DB.Delete(&window)
+
+	log.Printf("CancelOperatingTheaterMaintenance: Window %d cancelled", window.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Maintenance window cancelled successfully"})
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func validateOperativeReport(input models.OperativeReportRequest) error {
+	if input.DischargeDestination != "" && !input.DischargeDestination.IsValid() {
+		return fmt.Errorf("invalid discharge destination %q", input.DischargeDestination)
+	}
+	if input.ActualEndAt != nil && input.ActualEndAt.After(time.Now()) {
+		return errors.New("actual_end_at cannot be in the future")
+	}
+	return nil
+}
+
+// recordOperativeReport stores the report of a surgery that has just been
+// completed, correcting its end time when the report gives one, and captures
+// the actual against the estimated duration. Surgeries started before start
+// times were recorded get their scheduled start as the actual one.
+func recordOperativeReport(tx *gorm.DB, surgery *models.SurgerySchedule, input models.OperativeReportRequest) (models.OperativeReport, error) {
+	changed := false
+	if surgery.ActualStartAt == nil {
+		start := surgery.ScheduledAt
+		if surgery.ActualEndAt != nil && surgery.ActualEndAt.Before(start) {
+			start = *surgery.ActualEndAt
+		}
+		surgery.ActualStartAt = &start
+		changed = true
+	}
+	if input.ActualEndAt != nil {
+		if !input.ActualEndAt.After(*surgery.ActualStartAt) {
+			return models.OperativeReport{}, fmt.Errorf("actual_end_at must be after the surgery started at %s", surgery.ActualStartAt.Format(time.RFC3339))
+		}
+		surgery.ActualEndAt = input.ActualEndAt
+		changed = true
+	}
+	if changed {
+		if err := tx.Omit(clause.Associations).Save(surgery).Error; err != nil {
+			return models.OperativeReport{}, err
+		}
+	}
+
+	actual := int(math.Round(surgery.ActualEndAt.Sub(*surgery.ActualStartAt).Minutes()))
+	report := models.OperativeReport{
+		SurgeryScheduleID:    surgery.ID,
+		ProcedurePerformed:   input.ProcedurePerformed,
+		Findings:             input.Findings,
+		Complications:        input.Complications,
+		BloodLossML:          input.BloodLossML,
+		DischargeDestination: input.DischargeDestination,
+		Notes:                input.Notes,
+		ReportedBy:           input.ReportedBy,
+		ActualStartAt:        *surgery.ActualStartAt,
+		ActualEndAt:          *surgery.ActualEndAt,
+		ActualDuration:       actual,
+		EstimatedDuration:    surgery.EstimatedDuration,
+		DurationVariance:     actual - surgery.EstimatedDuration,
+	}
+	if report.ProcedurePerformed == "" {
+		report.ProcedurePerformed = surgery.SurgeryType
+	}
+	if report.Complications == nil {
+		report.Complications = []string{}
+	}
+
+	err := tx.Create(&report).Error
+	return report, err
+}
+
+func GetOperativeReport(c *gin.Context) {
+	log.Printf("GetOperativeReport: Request received for surgery ID %s", c.Param("id"))
+
+	var report models.OperativeReport
+
+	if err := config.DB.Where("surgery_schedule_id = ?", c.Param("id")).First(&report).Error; err != nil {
+		log.Printf("GetOperativeReport: No operative report for surgery %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Operative report not found!"})
+		return
+	}
+
+	log.Printf("GetOperativeReport: Found report %d for surgery %s", report.ID, c.Param("id"))
+	c.JSON(http.StatusOK, report)
+}
+
+// GetOperativeReports lists reports for analytics, filtered by the surgery's
+// type, lead doctor and completion date, with the average durations.
+func GetOperativeReports(c *gin.Context) {
+	log.Printf("GetOperativeReports: Request received for surgery_type=%s doctor_id=%s start=%s end=%s",
+		c.Query("surgery_type"), c.Query("doctor_id"), c.Query("start"), c.Query("end"))
+
+	query := config.DB.Order("actual_end_at")
+
+	surgeries := config.DB.Model(&models.SurgerySchedule{}).Select("id")
+	filtered := false
+	if surgeryType := c.Query("surgery_type"); surgeryType != "" {
+		surgeries = surgeries.Where("surgery_type = ?", surgeryType)
+		filtered = true
+	}
+	if doctorID := c.Query("doctor_id"); doctorID != "" {
+		surgeries = surgeries.Where("doctor_id = ?", doctorID)
+		filtered = true
+	}
+	if filtered {
+		query = query.Where("surgery_schedule_id IN (?)", surgeries)
+	}
+
+	for param, condition := range map[string]string{"start": "actual_end_at >= ?", "end": "actual_end_at < ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			log.Printf("GetOperativeReports: Invalid %s %s", param, value)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s time. Use RFC3339, e.g. 2026-01-02T09:00:00+05:30", param)})
+			return
+		}
+		query = query.Where(condition, parsed)
+	}
+
+	var reports []models.OperativeReport
+
+	if err := query.Find(&reports).Error; err != nil {
+		log.Printf("GetOperativeReports: Error fetching reports - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var actual, estimated, overruns int
+	for _, report := range reports {
+		actual += report.ActualDuration
+		estimated += report.EstimatedDuration
+		if report.DurationVariance > 0 {
+			overruns++
+		}
+	}
+	summary := gin.H{"count": len(reports), "overruns": overruns}
+	if len(reports) > 0 {
+		summary["average_actual_duration"] = float64(actual) / float64(len(reports))
+		summary["average_estimated_duration"] = float64(estimated) / float64(len(reports))
+		summary["average_duration_variance"] = float64(actual-estimated) / float64(len(reports))
+	}
+
+	log.Printf("GetOperativeReports: Found %d reports", len(reports))
+	c.JSON(http.StatusOK, gin.H{
+		"reports": reports,
+		"summary": summary,
+	})
+}
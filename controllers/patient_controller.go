@@ -9,6 +9,7 @@ import (
 	"CRUD-hospital-go/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func CreatePatient(c *gin.Context) {
@@ -22,7 +23,40 @@ func CreatePatient(c *gin.Context) {
 		return
 	}
 
-	config.DB.Create(&input)
+	if input.Deposit < 0 {
+		log.Printf("CreatePatient: Rejected negative deposit %s", input.Deposit)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Deposit cannot be negative"})
+		return
+	}
+
+	currency, err := normalizeCurrency(input.Currency)
+	if err != nil {
+		log.Printf("CreatePatient: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Currency = currency
+
+	openingDeposit := input.Deposit
+	input.Deposit = 0
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&input).Error; err != nil {
+			return err
+		}
+		if openingDeposit > 0 {
+			if _, err := recordDepositTransaction(tx, &input, models.DepositTopUp, openingDeposit, nil, "Initial deposit"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("CreatePatient: Failed to create patient - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	log.Printf("CreatePatient: Patient created successfully with ID %d", input.ID)
 	c.JSON(http.StatusOK, input)
@@ -71,11 +105,12 @@ func UpdatePatient(c *gin.Context) {
 	}
 
 	var input struct {
-		Name      *string  `json:"name"`
-		ContactNo *string  `json:"contact_no"`
-		Address   *string  `json:"address"`
-		DoctorID  *uint    `json:"doctor_id"`
-		Deposit   *float64 `json:"deposit"`
+		Name      *string        `json:"name"`
+		ContactNo *string        `json:"contact_no"`
+		Address   *string        `json:"address"`
+		DoctorID  *uint          `json:"doctor_id"`
+		Deposit   *models.Amount `json:"deposit"`
+		Currency  *string        `json:"currency"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -84,6 +119,21 @@ func UpdatePatient(c *gin.Context) {
 		return
 	}
 
+	if input.Deposit != nil {
+		log.Printf("UpdatePatient: Rejected direct deposit change for patient %s", id)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Deposit cannot be edited directly. Use /patient/:id/deposit/topup or /patient/:id/deposit/adjustment"})
+		return
+	}
+	if input.Currency != nil {
+		currency, err := normalizeCurrency(*input.Currency)
+		if err != nil {
+			log.Printf("UpdatePatient: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		patient.Currency = currency
+	}
+
 	if input.Name != nil {
 		patient.Name = *input.Name
 	}
@@ -96,12 +146,9 @@ func UpdatePatient(c *gin.Context) {
 	if input.DoctorID != nil {
 		patient.DoctorID = *input.DoctorID
 	}
-	if input.Deposit != nil {
-		patient.Deposit = *input.Deposit
-	}
 	patient.UpdatedAt = time.Now()
 
-	config.DB.Save(&patient)
+	config.DB.Omit("Deposit").Save(&patient)
 	log.Printf("UpdatePatient: Patient updated successfully with ID %s", id)
 	c.JSON(http.StatusOK, patient)
 }
@@ -0,0 +1,579 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultOptimizerOvertime = 2 * time.Hour
+	defaultOptimizerStep     = 15 * time.Minute
+	maxOptimizerRequests     = 100
+	// maxOptimizerTries bounds the bookings attempted for one request.
+	maxOptimizerTries = 200
+)
+
+type optimizerQuery struct {
+	Day      models.TimeSlot
+	Overtime time.Duration
+	Step     time.Duration
+	Commit   bool
+}
+
+// optimizerItem is a pending request together with the earliest start and
+// the latest end it accepts on the planned day.
+type optimizerItem struct {
+	Index       *int
+	Entry       *models.WaitlistEntry
+	Request     models.SurgeryScheduleRequest
+	SurgeryType models.SurgeryType
+	Window      models.TimeSlot
+}
+
+func (item optimizerItem) duration() time.Duration {
+	return time.Duration(item.Request.EstimatedDuration) * time.Minute
+}
+
+func (item optimizerItem) unscheduled(reason string) models.UnscheduledRequest {
+	result := models.UnscheduledRequest{
+		Index:       item.Index,
+		PatientID:   item.Request.PatientID,
+		DoctorID:    item.Request.DoctorID,
+		SurgeryType: item.Request.SurgeryType,
+		Reason:      reason,
+	}
+	if item.Entry != nil {
+		result.WaitlistEntryID = &item.Entry.ID
+	}
+	return result
+}
+
+// optimizerRequestProblem checks a request the way binding checks a single
+// booking, except that scheduled_at may be left out. A bad request is
+// reported as unscheduled instead of failing the whole plan.
+func optimizerRequestProblem(request models.SurgeryScheduleRequest) string {
+	switch {
+	case request.PatientID == 0:
+		return "patient_id is required"
+	case request.DoctorID == 0:
+		return "doctor_id is required"
+	case request.SurgeryType == "":
+		return "surgery_type is required"
+	case request.EstimatedDuration < 0:
+		return "estimated_duration cannot be negative"
+	case request.DepositRequired < 0:
+		return "deposit_required cannot be negative"
+	case request.MinCapacity < 0:
+		return "min_capacity cannot be negative"
+	case request.PostOpNights < 0:
+		return "post_op_nights cannot be negative"
+	}
+	for _, member := range request.Team {
+		if member.DoctorID == 0 {
+			return "team doctor_id is required"
+		}
+		if !member.Role.IsValid() {
+			return fmt.Sprintf("invalid team role %q", member.Role)
+		}
+	}
+	return ""
+}
+
+// optimizerItems prepares the posted requests and the waitlist entries the
+// same way ScheduleSurgery does. Requests that cannot be booked at any time
+// are returned as unscheduled straight away.
+func optimizerItems(tx *gorm.DB, input models.ScheduleOptimizationRequest, q optimizerQuery) ([]optimizerItem, []models.UnscheduledRequest, error) {
+	items := []optimizerItem{}
+	unscheduled := []models.UnscheduledRequest{}
+
+	earliest := q.Day.Start
+	if now := time.Now(); earliest.Before(now) {
+		earliest = now
+	}
+	horizon := models.TimeSlot{Start: earliest, End: q.Day.End.Add(q.Overtime)}
+
+	for i := range input.Requests {
+		index := i
+		item := optimizerItem{Index: &index, Request: input.Requests[i], Window: horizon}
+		if problem := optimizerRequestProblem(item.Request); problem != "" {
+			unscheduled = append(unscheduled, item.unscheduled(problem))
+			continue
+		}
+		if item.Request.ScheduledAt.After(item.Window.Start) {
+			item.Window.Start = item.Request.ScheduledAt
+		}
+		items = append(items, item)
+	}
+
+	if len(input.WaitlistEntryIDs) > 0 {
+		var entries []models.WaitlistEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", input.WaitlistEntryIDs).
+			Order("id").
+			Find(&entries).Error; err != nil {
+			return nil, nil, err
+		}
+		found := map[uint]bool{}
+		for i := range entries {
+			entry := &entries[i]
+			found[entry.ID] = true
+			item := optimizerItem{Entry: entry, Request: entry.Request, Window: horizon}
+			if entry.Status != models.WaitlistWaiting {
+				unscheduled = append(unscheduled, item.unscheduled(fmt.Sprintf("waitlist entry is %s", entry.Status)))
+				continue
+			}
+			if entry.EarliestAt.After(item.Window.Start) {
+				item.Window.Start = entry.EarliestAt
+			}
+			if entry.LatestAt.Before(item.Window.End) {
+				item.Window.End = entry.LatestAt
+			}
+			items = append(items, item)
+		}
+		for _, id := range input.WaitlistEntryIDs {
+			if !found[id] {
+				entryID := id
+				unscheduled = append(unscheduled, models.UnscheduledRequest{WaitlistEntryID: &entryID, Reason: "waitlist entry not found"})
+			}
+		}
+	}
+
+	prepared := []optimizerItem{}
+	for _, item := range items {
+		surgeryType, err := prepareSurgeryRequest(tx, &item.Request)
+		if err != nil {
+			unscheduled = append(unscheduled, item.unscheduled(err.Error()))
+			continue
+		}
+		item.SurgeryType = surgeryType
+		if item.Window.Start.Add(item.duration()).After(item.Window.End) {
+			unscheduled = append(unscheduled, item.unscheduled("does not fit between its earliest start and the end of the day plus overtime"))
+			continue
+		}
+		prepared = append(prepared, item)
+	}
+	return prepared, unscheduled, nil
+}
+
+// theaterFreeFrom returns when ot becomes free before start: the end of the
+// last surgery or maintenance window finishing by then plus turnover, or the
+// start of the day.
+func theaterFreeFrom(tx *gorm.DB, ot models.OperatingTheater, day models.TimeSlot, start time.Time) (time.Time, error) {
+	freeFrom := day.Start
+	turnover := ot.Turnover(config.OTTurnoverTime())
+
+	var surgeries []models.SurgerySchedule
+	if err := overlappingSurgeries(tx, day.Start.Add(-turnover), start).
+		Where("operating_theater_id = ?", ot.ID).
+		Find(&surgeries).Error; err != nil {
+		return freeFrom, err
+	}
+	for _, surgery := range surgeries {
+		if end := surgery.ScheduledEnd.Add(turnover); end.After(freeFrom) {
+			freeFrom = end
+		}
+	}
+
+	var windows []models.OTMaintenanceWindow
+	if err := tx.Where("operating_theater_id = ? AND start_at < ? AND end_at > ?", ot.ID, start, day.Start).
+		Find(&windows).Error; err != nil {
+		return freeFrom, err
+	}
+	for _, window := range windows {
+		if end := window.EndAt.Add(turnover); end.After(freeFrom) {
+			freeFrom = end
+		}
+	}
+	return freeFrom, nil
+}
+
+// optimizerStarts lists the start times worth trying for item: the step grid
+// across its window plus every moment a theater, the doctor or the patient
+// frees up, so surgeries can be packed back to back. Starts that clash with
+// the doctor's roster or bookings or the patient's surgeries are dropped.
+func optimizerStarts(tx *gorm.DB, item optimizerItem, theaters []models.OperatingTheater, step time.Duration) ([]time.Time, error) {
+	window := item.Window
+	duration := item.duration()
+
+	rostered, err := doctorRosteredSlots(tx, item.Request.DoctorID, window)
+	if err != nil {
+		return nil, err
+	}
+	busy, err := doctorBusySlots(tx, item.Request.DoctorID, window)
+	if err != nil {
+		return nil, err
+	}
+	patientBusy, err := patientBusySlots(tx, item.Request.PatientID, window)
+	if err != nil {
+		return nil, err
+	}
+	busy = append(busy, patientBusy...)
+
+	points := []time.Time{window.Start}
+	for start := alignToStep(window.Start, step); start.Before(window.End); start = start.Add(step) {
+		points = append(points, start)
+	}
+	for _, slot := range rostered {
+		points = append(points, slot.Start)
+	}
+	for _, slot := range busy {
+		points = append(points, slot.End)
+	}
+	for _, ot := range theaters {
+		turnover := ot.Turnover(config.OTTurnoverTime())
+		var surgeries []models.SurgerySchedule
+		if err := overlappingSurgeries(tx, window.Start.Add(-turnover), window.End).
+			Where("operating_theater_id = ?", ot.ID).
+			Find(&surgeries).Error; err != nil {
+			return nil, err
+		}
+		for _, surgery := range surgeries {
+			points = append(points, surgery.ScheduledEnd.Add(turnover))
+		}
+		var windows []models.OTMaintenanceWindow
+		if err := tx.Where("operating_theater_id = ? AND start_at < ? AND end_at > ?", ot.ID, window.End, window.Start).
+			Find(&windows).Error; err != nil {
+			return nil, err
+		}
+		for _, maintenance := range windows {
+			points = append(points, maintenance.EndAt.Add(turnover))
+		}
+	}
+
+	free := []models.TimeSlot{}
+	for _, roster := range rostered {
+		free = append(free, subtractSlots(roster, busy, duration)...)
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Before(points[j]) })
+	starts := []time.Time{}
+	for i, start := range points {
+		if i > 0 && start.Equal(points[i-1]) {
+			continue
+		}
+		slot := models.TimeSlot{Start: start, End: start.Add(duration)}
+		if slot.Start.Before(window.Start) || slot.End.After(window.End) {
+			continue
+		}
+		for _, gap := range free {
+			if !slot.Start.Before(gap.Start) && !slot.End.After(gap.End) {
+				starts = append(starts, start)
+				break
+			}
+		}
+	}
+	return starts, nil
+}
+
+// placeOptimizerItem books item at the earliest start that works, trying the
+// free theaters with the shortest idle gap before that start first. Each
+// attempt goes through bookSurgery under a savepoint so a failed one leaves
+// nothing behind. Failures other than a busy doctor or theater end the search.
+func placeOptimizerItem(tx *gorm.DB, item optimizerItem, q optimizerQuery) (models.SurgerySchedule, []models.Equipment, string, error) {
+	var surgery models.SurgerySchedule
+
+	constraints := constraintsFromRequest(item.Request, item.SurgeryType)
+	theaters, err := candidateTheaters(tx, constraints)
+	if err != nil {
+		return surgery, nil, err.Error(), nil
+	}
+
+	starts, err := optimizerStarts(tx, item, theaters, q.Step)
+	if err != nil {
+		return surgery, nil, "", err
+	}
+	if len(starts) == 0 {
+		return surgery, nil, "the doctor or patient has no free time long enough on this day", nil
+	}
+
+	tries := 0
+	reason := "no operating theater is free while the doctor is available"
+	for _, start := range starts {
+		slot := models.TimeSlot{Start: start, End: start.Add(item.duration())}
+
+		type fit struct {
+			ot  models.OperatingTheater
+			gap time.Duration
+		}
+		fits := []fit{}
+		for _, ot := range theaters {
+			conflict, err := findTheaterConflict(tx, ot, slot, 0)
+			if err != nil {
+				return surgery, nil, "", err
+			}
+			if conflict != "" {
+				continue
+			}
+			freeFrom, err := theaterFreeFrom(tx, ot, q.Day, start)
+			if err != nil {
+				return surgery, nil, "", err
+			}
+			fits = append(fits, fit{ot: ot, gap: start.Sub(freeFrom)})
+		}
+		sort.SliceStable(fits, func(i, j int) bool { return fits[i].gap < fits[j].gap })
+
+		for _, candidate := range fits {
+			if tries >= maxOptimizerTries {
+				return surgery, nil, fmt.Sprintf("gave up after %d attempts: %s", tries, reason), nil
+			}
+			tries++
+
+			request := item.Request
+			request.ScheduledAt = start
+			request.OperatingTheaterID = &candidate.ot.ID
+
+			if err := tx.SavePoint("optimizer_try").Error; err != nil {
+				return surgery, nil, "", err
+			}
+			booked, _, err := bookSurgery(tx, request, item.SurgeryType, false)
+			if err == nil {
+				var bookings []models.EquipmentBooking
+				if err := tx.Preload("Equipment").Where("surgery_schedule_id = ?", booked.ID).Find(&bookings).Error; err != nil {
+					return surgery, nil, "", err
+				}
+				units := make([]models.Equipment, 0, len(bookings))
+				for _, booking := range bookings {
+					units = append(units, booking.Equipment)
+				}
+				return booked, units, "", nil
+			}
+			if rollbackErr := tx.RollbackTo("optimizer_try").Error; rollbackErr != nil {
+				return surgery, nil, "", rollbackErr
+			}
+			if !isRetryableBookingError(err) {
+				return surgery, nil, err.Error(), nil
+			}
+			reason = err.Error()
+		}
+	}
+	return surgery, nil, reason, nil
+}
+
+// theaterUtilization reports, for every theater not under maintenance, how
+// much of the working day is booked or idle and how far surgeries starting
+// during the day run past its end.
+func theaterUtilization(tx *gorm.DB, day models.TimeSlot) ([]models.TheaterUtilization, error) {
+	var theaters []models.OperatingTheater
+	if err := tx.Where("status <> ?", models.OTStatusMaintenance).Order("id").Find(&theaters).Error; err != nil {
+		return nil, err
+	}
+
+	utilization := []models.TheaterUtilization{}
+	for _, ot := range theaters {
+		var surgeries []models.SurgerySchedule
+		if err := overlappingSurgeries(tx, day.Start, day.End).
+			Where("operating_theater_id = ?", ot.ID).
+			Find(&surgeries).Error; err != nil {
+			return nil, err
+		}
+		var windows []models.OTMaintenanceWindow
+		if err := tx.Where("operating_theater_id = ? AND start_at < ? AND end_at > ?", ot.ID, day.End, day.Start).
+			Find(&windows).Error; err != nil {
+			return nil, err
+		}
+
+		row := models.TheaterUtilization{OperatingTheaterID: ot.ID, OperatingTheaterName: ot.Name, Surgeries: len(surgeries)}
+		blocked := []models.TimeSlot{}
+		for _, surgery := range surgeries {
+			slot := surgery.Slot()
+			blocked = append(blocked, slot)
+			inDay := models.TimeSlot{Start: slot.Start, End: slot.End}
+			if inDay.Start.Before(day.Start) {
+				inDay.Start = day.Start
+			}
+			if inDay.End.After(day.End) {
+				row.OvertimeMinutes += int(inDay.End.Sub(day.End).Minutes())
+				inDay.End = day.End
+			}
+			row.BookedMinutes += int(inDay.Duration().Minutes())
+		}
+		for _, window := range windows {
+			blocked = append(blocked, window.Slot())
+		}
+		for _, gap := range subtractSlots(day, blocked, 0) {
+			row.IdleMinutes += int(gap.Duration().Minutes())
+		}
+		utilization = append(utilization, row)
+	}
+	return utilization, nil
+}
+
+// optimizeSchedule builds the day's list greedily: the most urgent and then
+// the longest requests are placed first, each at its earliest feasible start
+// in the theater left idle for the shortest time, which keeps theaters packed
+// and pushes overtime to the end of the list. Bookings are real, so the
+// caller rolls tx back for a preview.
+func optimizeSchedule(tx *gorm.DB, input models.ScheduleOptimizationRequest, q optimizerQuery) (models.ScheduleOptimizationResult, error) {
+	result := models.ScheduleOptimizationResult{
+		Day:         q.Day,
+		Committed:   q.Commit,
+		Assignments: []models.ScheduleAssignment{},
+	}
+
+	items, unscheduled, err := optimizerItems(tx, input, q)
+	if err != nil {
+		return result, err
+	}
+	result.Unscheduled = unscheduled
+
+	sort.SliceStable(items, func(i, j int) bool {
+		pi, pj := items[i].Request.Priority, items[j].Request.Priority
+		if pi != pj {
+			return pi.Preempts(pj)
+		}
+		return items[i].Request.EstimatedDuration > items[j].Request.EstimatedDuration
+	})
+
+	for _, item := range items {
+		surgery, units, reason, err := placeOptimizerItem(tx, item, q)
+		if err != nil {
+			return result, err
+		}
+		if reason != "" {
+			log.Printf("optimizeSchedule: %s surgery for patient %d left unscheduled - %s", item.Request.SurgeryType, item.Request.PatientID, reason)
+			result.Unscheduled = append(result.Unscheduled, item.unscheduled(reason))
+			continue
+		}
+
+		if item.Entry != nil {
+			item.Entry.Status = models.WaitlistBooked
+			item.Entry.SurgeryScheduleID = &surgery.ID
+			if err := tx.Save(item.Entry).Error; err != nil {
+				return result, err
+			}
+		}
+		if err := notifySurgeryDoctors(tx, surgery, fmt.Sprintf("Surgery %d for patient %d was booked at %s in operating theater %d by the schedule optimizer.",
+			surgery.ID, surgery.PatientID, surgery.ScheduledAt.Format(time.RFC3339), surgery.OperatingTheaterID)); err != nil {
+			return result, err
+		}
+
+		assignment := models.ScheduleAssignment{
+			TimeSlot:           surgery.Slot(),
+			Index:              item.Index,
+			PatientID:          surgery.PatientID,
+			DoctorID:           surgery.DoctorID,
+			SurgeryType:        surgery.SurgeryType,
+			Priority:           surgery.Priority,
+			OperatingTheaterID: surgery.OperatingTheaterID,
+		}
+		if item.Entry != nil {
+			assignment.WaitlistEntryID = &item.Entry.ID
+		}
+		if end := surgery.Slot().End; end.After(q.Day.End) {
+			assignment.OvertimeMinutes = int(end.Sub(q.Day.End).Minutes())
+		}
+		for _, unit := range units {
+			assignment.MovableEquipment = append(assignment.MovableEquipment, unit.Name)
+		}
+		if q.Commit {
+			id := surgery.ID
+			assignment.SurgeryScheduleID = &id
+		}
+		result.Assignments = append(result.Assignments, assignment)
+	}
+
+	sort.SliceStable(result.Assignments, func(i, j int) bool {
+		a, b := result.Assignments[i], result.Assignments[j]
+		if a.OperatingTheaterID != b.OperatingTheaterID {
+			return a.OperatingTheaterID < b.OperatingTheaterID
+		}
+		return a.Start.Before(b.Start)
+	})
+
+	result.Theaters, err = theaterUtilization(tx, q.Day)
+	if err != nil {
+		return result, err
+	}
+	for _, row := range result.Theaters {
+		result.TotalIdleMinutes += row.IdleMinutes
+		result.TotalOvertimeMinutes += row.OvertimeMinutes
+	}
+	return result, nil
+}
+
+func OptimizeSurgerySchedule(c *gin.Context) {
+	log.Println("OptimizeSurgerySchedule: Request received")
+
+	var input models.ScheduleOptimizationRequest
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("OptimizeSurgerySchedule: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(input.Requests)+len(input.WaitlistEntryIDs) == 0 {
+		log.Println("OptimizeSurgerySchedule: Nothing to schedule")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "requests or waitlist_entry_ids are required"})
+		return
+	}
+	if len(input.Requests)+len(input.WaitlistEntryIDs) > maxOptimizerRequests {
+		log.Printf("OptimizeSurgerySchedule: Too many requests (%d)", len(input.Requests)+len(input.WaitlistEntryIDs))
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d requests can be optimized at once", maxOptimizerRequests)})
+		return
+	}
+
+	date := startOfDay(time.Now()).AddDate(0, 0, 1)
+	if input.Date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", input.Date, time.Local)
+		if err != nil {
+			log.Printf("OptimizeSurgerySchedule: Invalid date %s", input.Date)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		date = parsed
+	}
+
+	dayStart, dayEnd := config.WorkingDay(date)
+	q := optimizerQuery{
+		Day:      models.TimeSlot{Start: dayStart, End: dayEnd},
+		Overtime: defaultOptimizerOvertime,
+		Step:     defaultOptimizerStep,
+		Commit:   input.Commit,
+	}
+	if input.MaxOvertimeMinutes != nil {
+		q.Overtime = time.Duration(*input.MaxOvertimeMinutes) * time.Minute
+	}
+	if input.StepMinutes > 0 {
+		q.Step = time.Duration(input.StepMinutes) * time.Minute
+	}
+	if !q.Day.End.Add(q.Overtime).After(time.Now()) {
+		log.Printf("OptimizeSurgerySchedule: Day %s is over", date.Format("2006-01-02"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot plan a day that is already over"})
+		return
+	}
+
+	var result models.ScheduleOptimizationResult
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = optimizeSchedule(tx, input, q)
+		if err != nil {
+			return err
+		}
+		if !q.Commit {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Printf("OptimizeSurgerySchedule: Optimization failed - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result.Date = date.Format("2006-01-02")
+
+	log.Printf("OptimizeSurgerySchedule: %d assigned, %d unscheduled, %d idle and %d overtime minutes (committed: %t)",
+		len(result.Assignments), len(result.Unscheduled), result.TotalIdleMinutes, result.TotalOvertimeMinutes, result.Committed)
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findOrCreateSpecialties maps specialty names onto catalog rows, creating the
+// ones that do not exist yet so doctors never get duplicate specialties.
+func findOrCreateSpecialties(tx *gorm.DB, names []string) ([]models.Specialty, error) {
+	resolved := []models.Specialty{}
+	for _, input := range names {
+		name := strings.TrimSpace(input)
+		if name == "" {
+			return nil, errors.New("specialty name cannot be empty")
+		}
+		var specialty models.Specialty
+		if err := tx.Where(models.Specialty{Name: name}).FirstOrCreate(&specialty).Error; err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, specialty)
+	}
+	return resolved, nil
+}
+
+// withSpecialty scopes a doctor query to doctors holding the named specialty.
+func withSpecialty(tx *gorm.DB, name string) *gorm.DB {
+	return tx.Where("id IN (?)", tx.Session(&gorm.Session{NewDB: true}).
+		Table("doctor_specialties").
+		Select("doctor_specialties.doctor_id").
+		Joins("JOIN specialties ON specialties.id = doctor_specialties.specialty_id").
+		Where("specialties.name = ? AND specialties.deleted_at IS NULL", name))
+}
+
+// checkDoctorCredentials makes sure the doctor holds the specialty the surgery
+// type requires and has been granted the privilege to perform it.
+func checkDoctorCredentials(tx *gorm.DB, doctorID uint, surgeryType models.SurgeryType) error {
+	if surgeryType.RequiredSpecialty != "" {
+		var count int64
+		if err := withSpecialty(tx.Model(&models.Doctor{}), surgeryType.RequiredSpecialty).
+			Where("id = ?", doctorID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("doctor %d does not have the %s specialty required for %s", doctorID, surgeryType.RequiredSpecialty, surgeryType.Code)
+		}
+	}
+
+	var privilege models.DoctorPrivilege
+	err := tx.Where("doctor_id = ? AND surgery_type_id = ?", doctorID, surgeryType.ID).First(&privilege).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("doctor %d is not credentialed to perform %s", doctorID, surgeryType.Code)
+	}
+	return err
+}
+
+func CreateSpecialty(c *gin.Context) {
+	log.Println("CreateSpecialty: Request received")
+
+	var input models.Specialty
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateSpecialty: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if err := config.DB.Create(&input).Error; err != nil {
+		log.Printf("CreateSpecialty: Failed to create specialty - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("CreateSpecialty: Specialty created successfully with ID %d", input.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func GetAllSpecialties(c *gin.Context) {
+	log.Println("GetAllSpecialties: Request received")
+
+	var specialties []models.Specialty
+
+	if err := config.DB.Order("name").Find(&specialties).Error; err != nil {
+		log.Printf("GetAllSpecialties: Error fetching specialties - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetAllSpecialties: Found %d specialties", len(specialties))
+	c.JSON(http.StatusOK, specialties)
+}
+
+func AddDoctorSpecialty(c *gin.Context) {
+	log.Printf("AddDoctorSpecialty: Request received for doctor ID %s", c.Param("id"))
+
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("AddDoctorSpecialty: Doctor not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found!"})
+		return
+	}
+
+	var input models.Specialty
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("AddDoctorSpecialty: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	specialties, err := findOrCreateSpecialties(config.DB, []string{input.Name})
+	if err == nil {
+		err = config.DB.Model(&doctor).Association("Specialties").Append(specialties)
+	}
+	if err != nil {
+		log.Printf("AddDoctorSpecialty: Failed to add specialty - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config.DB.Preload("Specialties").First(&doctor, doctor.ID)
+
+	log.Printf("AddDoctorSpecialty: Specialty %s added to doctor %d", input.Name, doctor.ID)
+	c.JSON(http.StatusOK, doctor)
+}
+
+func RemoveDoctorSpecialty(c *gin.Context) {
+	log.Printf("RemoveDoctorSpecialty: Request received for doctor ID %s, specialty ID %s", c.Param("id"), c.Param("specialty_id"))
+
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("RemoveDoctorSpecialty: Doctor not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found!"})
+		return
+	}
+
+	var specialty models.Specialty
+	if err := config.DB.First(&specialty, "id = ?", c.Param("specialty_id")).Error; err != nil {
+		log.Printf("RemoveDoctorSpecialty: Specialty not found with ID %s", c.Param("specialty_id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Specialty not found!"})
+		return
+	}
+
+	if err := config.DB.Model(&doctor).Association("Specialties").Delete(&specialty); err != nil {
+		log.Printf("RemoveDoctorSpecialty: Failed to remove specialty - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("RemoveDoctorSpecialty: Specialty %d removed from doctor %d", specialty.ID, doctor.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Specialty removed successfully"})
+}
+
+func GetDoctorPrivileges(c *gin.Context) {
+	log.Printf("GetDoctorPrivileges: Request received for doctor ID %s", c.Param("id"))
+
+	var privileges []models.DoctorPrivilege
+
+	if err := config.DB.Preload("SurgeryType").Where("doctor_id = ?", c.Param("id")).Find(&privileges).Error; err != nil {
+		log.Printf("GetDoctorPrivileges: Error fetching privileges - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetDoctorPrivileges: Found %d privileges for doctor %s", len(privileges), c.Param("id"))
+	c.JSON(http.StatusOK, privileges)
+}
+
+func GrantDoctorPrivilege(c *gin.Context) {
+	log.Printf("GrantDoctorPrivilege: Request received for doctor ID %s", c.Param("id"))
+
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GrantDoctorPrivilege: Doctor not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found!"})
+		return
+	}
+
+	var input models.DoctorPrivilege
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("GrantDoctorPrivilege: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var surgeryType models.SurgeryType
+	if err := config.DB.First(&surgeryType, "id = ?", input.SurgeryTypeID).Error; err != nil {
+		log.Printf("GrantDoctorPrivilege: Surgery type not found with ID %d", input.SurgeryTypeID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Surgery type not found!"})
+		return
+	}
+
+	privilege := models.DoctorPrivilege{DoctorID: doctor.ID, SurgeryTypeID: surgeryType.ID}
+	if err := config.DB.Where(privilege).FirstOrCreate(&privilege).Error; err != nil {
+		log.Printf("GrantDoctorPrivilege: Failed to grant privilege - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	privilege.SurgeryType = surgeryType
+
+	log.Printf("GrantDoctorPrivilege: Doctor %d credentialed for %s", doctor.ID, surgeryType.Code)
+	c.JSON(http.StatusCreated, privilege)
+}
+
+func RevokeDoctorPrivilege(c *gin.Context) {
+	log.Printf("RevokeDoctorPrivilege: Request received for doctor ID %s, surgery type ID %s", c.Param("id"), c.Param("surgery_type_id"))
+
+	var privilege models.DoctorPrivilege
+
+	if err := config.DB.First(&privilege, "doctor_id = ? AND surgery_type_id = ?", c.Param("id"), c.Param("surgery_type_id")).Error; err != nil {
+		log.Printf("RevokeDoctorPrivilege: Privilege not found")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Privilege not found!"})
+		return
+	}
+
+	config.DB.Unscoped().Delete(&privilege)
+
+	log.Printf("RevokeDoctorPrivilege: Privilege %d revoked", privilege.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Privilege revoked successfully"})
+}
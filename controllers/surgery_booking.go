@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"gorm.io/gorm"
+)
+
+// errDryRun rolls back a transaction that only checked whether a booking
+// would succeed.
+var errDryRun = errors.New("dry run")
+
+// prepareSurgeryRequest validates the parts of a request that do not depend
+// on availability and fills in the surgery type defaults.
+func prepareSurgeryRequest(tx *gorm.DB, request *models.SurgeryScheduleRequest) (models.SurgeryType, error) {
+	if _, err := normalizeCurrency(request.Currency); err != nil {
+		return models.SurgeryType{}, err
+	}
+
+	if request.Priority == "" {
+		request.Priority = models.SurgeryPriorityElective
+	}
+	if !request.Priority.IsValid() {
+		return models.SurgeryType{}, fmt.Errorf("invalid priority %q", request.Priority)
+	}
+
+	return resolveSurgeryType(tx, request)
+}
+
+// isRetryableBookingError reports whether a booking failed only because
+// nothing was free, so the same request may succeed at another time.
+func isRetryableBookingError(err error) bool {
+	return errors.Is(err, errNoOperatingTheater) || errors.Is(err, errDoctorUnavailable) ||
+		errors.Is(err, errNoBedAvailable) || errors.Is(err, errPatientUnavailable)
+}
+
+// bookSurgery books a prepared request inside tx. It picks a theater, with
+// preempt letting urgent requests bump lower-priority surgeries, checks the
+// doctor, team and patient, books equipment, copies the pre-op checklist,
+// reserves the post-op bed and holds the deposit. It returns the new surgery
+// and any surgeries it displaced.
+func bookSurgery(tx *gorm.DB, request models.SurgeryScheduleRequest, surgeryType models.SurgeryType, preempt bool) (models.SurgerySchedule, []models.SurgerySchedule, error) {
+	var surgery models.SurgerySchedule
+	var displaced []models.SurgerySchedule
+
+	deposit := request.DepositRequired
+	if request.Priority.DefersBilling() {
+		deposit = 0
+	}
+
+	slot := models.TimeSlot{
+		Start: request.ScheduledAt,
+		End:   request.ScheduledAt.Add(time.Duration(request.EstimatedDuration) * time.Minute),
+	}
+
+	constraints := constraintsFromRequest(request, surgeryType)
+	ot, units, err := selectOperatingTheater(tx, constraints, slot, 0)
+	if err != nil && preempt && request.Priority.Preempts(models.SurgeryPriorityElective) {
+		log.Printf("bookSurgery: No free Operating Theater for %s surgery (%v), preempting", request.Priority, err)
+		ot, units, displaced, err = preemptOperatingTheater(tx, constraints, slot, request.Priority)
+	}
+	if err != nil {
+		log.Printf("bookSurgery: No suitable Operating Theater - %v", err)
+		return surgery, nil, err
+	}
+	log.Printf("bookSurgery: Selected OT with ID %d", ot.ID)
+
+	if _, err := lockAvailableDoctor(tx, request.DoctorID, slot, 0); err != nil {
+		log.Printf("bookSurgery: Doctor %d unavailable - %v", request.DoctorID, err)
+		return surgery, nil, err
+	}
+
+	if err := checkDoctorCredentials(tx, request.DoctorID, surgeryType); err != nil {
+		log.Printf("bookSurgery: Doctor %d not qualified - %v", request.DoctorID, err)
+		return surgery, nil, err
+	}
+
+	if err := validateSurgicalTeam(tx, request.DoctorID, request.Team, slot, 0); err != nil {
+		log.Printf("bookSurgery: Surgical team rejected - %v", err)
+		return surgery, nil, err
+	}
+
+	patient, err := lockPatient(tx, request.PatientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("bookSurgery: Patient not found with ID %d", request.PatientID)
+			return surgery, nil, errors.New("patient not found")
+		}
+		return surgery, nil, err
+	}
+	if err := checkPatientFree(tx, request.PatientID, slot, 0, 0); err != nil {
+		log.Printf("bookSurgery: Patient %d unavailable - %v", request.PatientID, err)
+		return surgery, nil, err
+	}
+
+	surgery = models.SurgerySchedule{
+		PatientID:          request.PatientID,
+		DoctorID:           request.DoctorID,
+		OperatingTheaterID: ot.ID,
+		SurgeryType:        request.SurgeryType,
+		ScheduledAt:        request.ScheduledAt,
+		EstimatedDuration:  request.EstimatedDuration,
+		DepositDeducted:    deposit,
+		Currency:           config.Currency(),
+		Status:             models.SurgeryStatusScheduled,
+		Priority:           request.Priority,
+		Notes:              request.Notes,
+		RequiredEquipment:  constraints.RequiredEquipment,
+		MinCapacity:        request.MinCapacity,
+		RequiredFloor:      request.Floor,
+		DeferredBilling:    request.Priority.DefersBilling(),
+		PostOpWardID:       request.PostOpWardID,
+	}
+	if surgery.PostOpWardID != nil {
+		surgery.PostOpNights = request.PostOpNights
+		if surgery.PostOpNights == 0 {
+			surgery.PostOpNights = 1
+		}
+	}
+
+	if err := tx.Create(&surgery).Error; err != nil {
+		log.Printf("bookSurgery: Failed to create surgery schedule - %v", err)
+		return surgery, nil, errors.New("failed to create surgery schedule")
+	}
+
+	if err := bookEquipment(tx, surgery, units); err != nil {
+		log.Printf("bookSurgery: Failed to book equipment - %v", err)
+		return surgery, nil, errors.New("failed to book equipment")
+	}
+
+	if err := createSurgicalTeam(tx, surgery.ID, request.Team); err != nil {
+		log.Printf("bookSurgery: Failed to assign surgical team - %v", err)
+		return surgery, nil, errors.New("failed to assign surgical team")
+	}
+
+	if err := createSurgeryChecklist(tx, surgery.ID, surgeryType); err != nil {
+		log.Printf("bookSurgery: Failed to create pre-op checklist - %v", err)
+		return surgery, nil, errors.New("failed to create pre-op checklist")
+	}
+
+	if err := reservePostOpBed(tx, &surgery); err != nil {
+		return surgery, nil, err
+	}
+
+	if deposit > 0 {
+		if _, err := recordDepositTransaction(tx, &patient, models.DepositHold, deposit, &surgery.ID, "Deposit held for surgery"); err != nil {
+			if errors.Is(err, errInsufficientDeposit) {
+				log.Printf("bookSurgery: Insufficient deposit for patient %d (has %s, needs %s)", request.PatientID, patient.Deposit, deposit)
+				return surgery, nil, errors.New("insufficient patient deposit for surgery")
+			}
+			log.Printf("bookSurgery: Failed to hold patient deposit - %v", err)
+			return surgery, nil, errors.New("failed to deduct patient deposit")
+		}
+	} else if surgery.DeferredBilling {
+		log.Printf("bookSurgery: %s surgery %d booked without deposit, billing deferred", surgery.Priority, surgery.ID)
+	}
+
+	if len(displaced) > 0 {
+		displaced, err = relocateDisplacedSurgeries(tx, surgery, displaced)
+		if err != nil {
+			log.Printf("bookSurgery: Failed to relocate displaced surgeries - %v", err)
+			return surgery, nil, errors.New("failed to relocate displaced surgeries")
+		}
+	}
+
+	return surgery, displaced, nil
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// icsEscape escapes a TEXT value as required by RFC 5545 section 3.3.11.
+func icsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "").Replace(value)
+}
+
+// icsWriteLine writes a content line, folding it after 75 octets without
+// splitting a UTF-8 sequence.
+func icsWriteLine(b *strings.Builder, name, value string) {
+	line := name + ":" + value
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// icsSequence grows every time the surgery moves or its status gets worse, so
+// calendar clients replace the copy they already have. Each reschedule is
+// worth more than any status change in between.
+func icsSequence(surgery models.SurgerySchedule) int {
+	sequence := 3 * len(surgery.Reschedules)
+	switch surgery.Status {
+	case models.SurgeryStatusPostponed:
+		sequence++
+	case models.SurgeryStatusCancelled, models.SurgeryStatusNoShow:
+		sequence += 2
+	}
+	return sequence
+}
+
+func icsStatus(status models.SurgeryStatus) string {
+	switch status {
+	case models.SurgeryStatusCancelled, models.SurgeryStatusPostponed, models.SurgeryStatusNoShow:
+		return "CANCELLED"
+	default:
+		return "CONFIRMED"
+	}
+}
+
+// surgeryRole names what the doctor does in the surgery, for the event
+// description of a doctor's feed.
+func surgeryRole(surgery models.SurgerySchedule, doctorID uint) string {
+	if surgery.DoctorID == doctorID {
+		return "Lead surgeon"
+	}
+	for _, member := range surgery.Team {
+		if member.DoctorID == doctorID {
+			return string(member.Role)
+		}
+	}
+	return ""
+}
+
+// writeSurgeryEvent renders one surgery as a VEVENT. The patient is only
+// named by ID since feeds end up in third-party calendars.
+func writeSurgeryEvent(b *strings.Builder, surgery models.SurgerySchedule, role string, stamp time.Time) {
+	slot := surgery.Slot()
+
+	description := []string{
+		fmt.Sprintf("Patient ID: %d", surgery.PatientID),
+		fmt.Sprintf("Priority: %s", surgery.Priority),
+		fmt.Sprintf("Status: %s", surgery.Status),
+	}
+	if role != "" {
+		description = append(description, "Role: "+role)
+	}
+	if surgery.SurgerySeriesID != nil {
+		description = append(description, fmt.Sprintf("Series %d, stage %d", *surgery.SurgerySeriesID, surgery.SeriesStage))
+	}
+
+	b.WriteString("BEGIN:VEVENT\r\n")
+	icsWriteLine(b, "UID", fmt.Sprintf("surgery-%d@%s", surgery.ID, config.CalendarDomain()))
+	icsWriteLine(b, "DTSTAMP", stamp.UTC().Format(icsTimeFormat))
+	icsWriteLine(b, "LAST-MODIFIED", surgery.UpdatedAt.UTC().Format(icsTimeFormat))
+	icsWriteLine(b, "SEQUENCE", fmt.Sprint(icsSequence(surgery)))
+	icsWriteLine(b, "DTSTART", slot.Start.UTC().Format(icsTimeFormat))
+	icsWriteLine(b, "DTEND", slot.End.UTC().Format(icsTimeFormat))
+	icsWriteLine(b, "SUMMARY", icsEscape(fmt.Sprintf("Surgery: %s (patient %d)", surgery.SurgeryType, surgery.PatientID)))
+	if surgery.OperatingTheater.ID != 0 {
+		icsWriteLine(b, "LOCATION", icsEscape(fmt.Sprintf("%s, floor %d", surgery.OperatingTheater.Name, surgery.OperatingTheater.Floor)))
+	}
+	icsWriteLine(b, "DESCRIPTION", icsEscape(strings.Join(description, "\n")))
+	icsWriteLine(b, "STATUS", icsStatus(surgery.Status))
+	if surgery.Status == models.SurgeryStatusScheduled {
+		icsWriteLine(b, "TRANSP", "OPAQUE")
+	} else {
+		icsWriteLine(b, "TRANSP", "TRANSPARENT")
+	}
+	b.WriteString("END:VEVENT\r\n")
+}
+
+// renderSurgeryCalendar renders a feed. Cancelled and postponed surgeries
+// stay in it with STATUS:CANCELLED so subscribers drop them.
+func renderSurgeryCalendar(name string, surgeries []models.SurgerySchedule, role func(models.SurgerySchedule) string) []byte {
+	var b strings.Builder
+	stamp := time.Now()
+
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	icsWriteLine(&b, "VERSION", "2.0")
+	icsWriteLine(&b, "PRODID", "-//CRUD-hospital-go//Surgery Schedule//EN")
+	icsWriteLine(&b, "CALSCALE", "GREGORIAN")
+	icsWriteLine(&b, "METHOD", "PUBLISH")
+	icsWriteLine(&b, "X-WR-CALNAME", icsEscape(name))
+	for _, surgery := range surgeries {
+		writeSurgeryEvent(&b, surgery, role(surgery), stamp)
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	return []byte(b.String())
+}
+
+// calendarSurgeries loads the surgeries of a feed, leaving out those that
+// ended longer ago than the calendar history.
+func calendarSurgeries(query *gorm.DB) ([]models.SurgerySchedule, error) {
+	var surgeries []models.SurgerySchedule
+	err := query.Preload("OperatingTheater").Preload("Team").Preload("Reschedules").
+		Where("scheduled_end > ?", time.Now().Add(-config.CalendarHistory())).
+		Order("scheduled_at").
+		Find(&surgeries).Error
+	return surgeries, err
+}
+
+func GetDoctorSurgeryCalendar(c *gin.Context) {
+	log.Printf("GetDoctorSurgeryCalendar: Request received for doctor_id %s", c.Param("doctor_id"))
+
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, "id = ?", c.Param("doctor_id")).Error; err != nil {
+		log.Printf("GetDoctorSurgeryCalendar: Doctor not found with ID %s", c.Param("doctor_id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found!"})
+		return
+	}
+
+	surgeries, err := calendarSurgeries(involvingDoctor(config.DB, doctor.ID))
+	if err != nil {
+		log.Printf("GetDoctorSurgeryCalendar: Error fetching surgeries - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	calendar := renderSurgeryCalendar(fmt.Sprintf("Operating list - %s", doctor.Name), surgeries, func(surgery models.SurgerySchedule) string {
+		return surgeryRole(surgery, doctor.ID)
+	})
+
+	log.Printf("GetDoctorSurgeryCalendar: Rendered %d surgeries for doctor %d", len(surgeries), doctor.ID)
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="doctor-%d.ics"`, doctor.ID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", calendar)
+}
+
+func GetOperatingTheaterSurgeryCalendar(c *gin.Context) {
+	log.Printf("GetOperatingTheaterSurgeryCalendar: Request received for operating_theater_id %s", c.Param("operating_theater_id"))
+
+	var ot models.OperatingTheater
+	if err := config.DB.First(&ot, "id = ?", c.Param("operating_theater_id")).Error; err != nil {
+		log.Printf("GetOperatingTheaterSurgeryCalendar: OT not found with ID %s", c.Param("operating_theater_id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Operating Theater not found!"})
+		return
+	}
+
+	surgeries, err := calendarSurgeries(config.DB.Where("operating_theater_id = ?", ot.ID))
+	if err != nil {
+		log.Printf("GetOperatingTheaterSurgeryCalendar: Error fetching surgeries - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	calendar := renderSurgeryCalendar(fmt.Sprintf("%s schedule", ot.Name), surgeries, func(models.SurgerySchedule) string {
+		return ""
+	})
+
+	log.Printf("GetOperatingTheaterSurgeryCalendar: Rendered %d surgeries for OT %d", len(surgeries), ot.ID)
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="operating-theater-%d.ics"`, ot.ID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", calendar)
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errDoctorUnavailable means the doctor exists but is busy, off roster or on
+// leave for the requested slot.
+var errDoctorUnavailable = errors.New("doctor unavailable")
+
+// overlappingSurgeries scopes a query to active surgeries whose booked
+// interval [scheduled_at, scheduled_end) intersects [start, end).
+func overlappingSurgeries(tx *gorm.DB, start, end time.Time) *gorm.DB {
+	return tx.Where("scheduled_at < ? AND scheduled_end > ? AND status NOT IN ?",
+		end, start, models.InactiveSurgeryStatuses)
+}
+
+// overlappingMaintenance scopes a query to the maintenance windows of a
+// theater that come within turnover of [start, end). Turnover is kept on both
+// sides of a window, as between two surgeries: cleaning up before maintenance
+// starts and getting the room ready again after it ends.
+func overlappingMaintenance(tx *gorm.DB, otID uint, start, end time.Time, turnover time.Duration) *gorm.DB {
+	return tx.Where("operating_theater_id = ? AND start_at < ? AND end_at > ?", otID, end.Add(turnover), start.Add(-turnover))
+}
+
+// findTheaterConflict describes what blocks ot during slot: another surgery
+// or a maintenance window, either with the turnover interval around it. It
+// returns an empty string when the theater is free.
+func findTheaterConflict(tx *gorm.DB, ot models.OperatingTheater, slot models.TimeSlot, excludeSurgeryID uint) (string, error) {
+	turnover := ot.Turnover(config.OTTurnoverTime())
+
+	query := overlappingSurgeries(tx.Model(&models.SurgerySchedule{}), slot.Start.Add(-turnover), slot.End.Add(turnover)).
+		Where("operating_theater_id = ?", ot.ID)
+	if excludeSurgeryID != 0 {
+		query = query.Where("id <> ?", excludeSurgeryID)
+	}
+
+	var existing models.SurgerySchedule
+	err := query.Order("scheduled_at").First(&existing).Error
+	if err == nil {
+		return fmt.Sprintf("surgery %d is booked from %s to %s plus %s turnover", existing.ID,
+			existing.ScheduledAt.Format(time.RFC3339), existing.ScheduledEnd.Format(time.RFC3339), turnover), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	var window models.OTMaintenanceWindow
+	err = overlappingMaintenance(tx, ot.ID, slot.Start, slot.End, turnover).
+		Order("start_at").
+		First(&window).Error
+	if err == nil {
+		return fmt.Sprintf("maintenance is planned from %s to %s plus %s turnover", window.StartAt.Format(time.RFC3339), window.EndAt.Format(time.RFC3339), turnover), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	return "", nil
+}
+
+func isOperatingTheaterFree(tx *gorm.DB, ot models.OperatingTheater, slot models.TimeSlot, excludeSurgeryID uint) (bool, error) {
+	conflict, err := findTheaterConflict(tx, ot, slot, excludeSurgeryID)
+	return conflict == "", err
+}
+
+// involvingDoctor scopes a surgery query to surgeries the doctor leads or is
+// part of the surgical team for.
+func involvingDoctor(tx *gorm.DB, doctorID interface{}) *gorm.DB {
+	return tx.Where("doctor_id = ? OR id IN (?)", doctorID,
+		tx.Session(&gorm.Session{NewDB: true}).Model(&models.SurgicalTeamMember{}).
+			Select("surgery_schedule_id").
+			Where("doctor_id = ?", doctorID))
+}
+
+// findDoctorConflict returns the first active surgery of the doctor that falls
+// within the configured buffer of [start, end), or nil when the doctor is free.
+func findDoctorConflict(tx *gorm.DB, doctorID uint, start, end time.Time, excludeSurgeryID uint) (*models.SurgerySchedule, error) {
+	buffer := config.DoctorBufferTime()
+	query := involvingDoctor(overlappingSurgeries(tx.Model(&models.SurgerySchedule{}), start.Add(-buffer), end.Add(buffer)), doctorID)
+	if excludeSurgeryID != 0 {
+		query = query.Where("id <> ?", excludeSurgeryID)
+	}
+
+	var existing models.SurgerySchedule
+	err := query.Order("scheduled_at").First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// overlappingAppointments scopes a query to active appointments whose
+// interval [scheduled_at, scheduled_end) intersects [start, end).
+func overlappingAppointments(tx *gorm.DB, start, end time.Time) *gorm.DB {
+	return tx.Where("scheduled_at < ? AND scheduled_end > ? AND status NOT IN ?",
+		end, start, models.InactiveAppointmentStatuses)
+}
+
+// findAppointmentConflict returns the first active appointment of the doctor
+// intersecting [start, end), or nil when there is none.
+func findAppointmentConflict(tx *gorm.DB, doctorID uint, start, end time.Time, excludeAppointmentID uint) (*models.Appointment, error) {
+	query := overlappingAppointments(tx.Model(&models.Appointment{}), start, end).Where("doctor_id = ?", doctorID)
+	if excludeAppointmentID != 0 {
+		query = query.Where("id <> ?", excludeAppointmentID)
+	}
+
+	var existing models.Appointment
+	err := query.Order("scheduled_at").First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// doctorBusySlots lists the doctor's surgeries and appointments touching the
+// window, widened by the buffer time on both sides, as seen when planning a
+// surgery.
+func doctorBusySlots(tx *gorm.DB, doctorID uint, window models.TimeSlot) ([]models.TimeSlot, error) {
+	busy, err := doctorSurgerySlots(tx, doctorID, window)
+	if err != nil {
+		return nil, err
+	}
+	appointments, err := doctorAppointmentSlots(tx, doctorID, window, config.DoctorBufferTime())
+	if err != nil {
+		return nil, err
+	}
+	return append(busy, appointments...), nil
+}
+
+// doctorAppointmentSlots lists the doctor's active appointments touching the
+// window, widened by pad on both sides.
+func doctorAppointmentSlots(tx *gorm.DB, doctorID uint, window models.TimeSlot, pad time.Duration) ([]models.TimeSlot, error) {
+	var appointments []models.Appointment
+	if err := overlappingAppointments(tx, window.Start.Add(-pad), window.End.Add(pad)).
+		Where("doctor_id = ?", doctorID).
+		Order("scheduled_at").
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	busy := make([]models.TimeSlot, 0, len(appointments))
+	for _, appointment := range appointments {
+		busy = append(busy, models.TimeSlot{
+			Start: appointment.ScheduledAt.Add(-pad),
+			End:   appointment.ScheduledEnd.Add(pad),
+		})
+	}
+	return busy, nil
+}
+
+// doctorSurgerySlots lists the doctor's surgeries touching the window,
+// widened by the buffer time on both sides.
+func doctorSurgerySlots(tx *gorm.DB, doctorID uint, window models.TimeSlot) ([]models.TimeSlot, error) {
+	buffer := config.DoctorBufferTime()
+
+	var surgeries []models.SurgerySchedule
+	if err := involvingDoctor(overlappingSurgeries(tx, window.Start.Add(-buffer), window.End.Add(buffer)), doctorID).
+		Order("scheduled_at").
+		Find(&surgeries).Error; err != nil {
+		return nil, err
+	}
+
+	busy := make([]models.TimeSlot, 0, len(surgeries))
+	for _, surgery := range surgeries {
+		busy = append(busy, models.TimeSlot{
+			Start: surgery.ScheduledAt.Add(-buffer),
+			End:   surgery.ScheduledEnd.Add(buffer),
+		})
+	}
+	return busy, nil
+}
+
+// subtractSlots returns the parts of window not covered by busy, keeping only
+// gaps of at least minLength.
+func subtractSlots(window models.TimeSlot, busy []models.TimeSlot, minLength time.Duration) []models.TimeSlot {
+	sorted := append([]models.TimeSlot(nil), busy...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	free := []models.TimeSlot{}
+	cursor := window.Start
+	for _, slot := range sorted {
+		if !slot.End.After(cursor) {
+			continue
+		}
+		if slot.Start.After(cursor) {
+			gapEnd := slot.Start
+			if gapEnd.After(window.End) {
+				gapEnd = window.End
+			}
+			if gap := (models.TimeSlot{Start: cursor, End: gapEnd}); gap.Duration() > 0 && gap.Duration() >= minLength {
+				free = append(free, gap)
+			}
+		}
+		cursor = slot.End
+		if !cursor.Before(window.End) {
+			return free
+		}
+	}
+	if gap := (models.TimeSlot{Start: cursor, End: window.End}); gap.Duration() > 0 && gap.Duration() >= minLength {
+		free = append(free, gap)
+	}
+	return free
+}
+
+// mergeSlots joins overlapping or touching slots into a sorted list.
+func mergeSlots(slots []models.TimeSlot) []models.TimeSlot {
+	sorted := append([]models.TimeSlot(nil), slots...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	merged := []models.TimeSlot{}
+	for _, slot := range sorted {
+		if slot.Duration() <= 0 {
+			continue
+		}
+		if last := len(merged) - 1; last >= 0 && !slot.Start.After(merged[last].End) {
+			if slot.End.After(merged[last].End) {
+				merged[last].End = slot.End
+			}
+			continue
+		}
+		merged = append(merged, slot)
+	}
+	return merged
+}
+
+// lockAvailableDoctor locks the doctor row and makes sure they are rostered
+// and free for slot, with the buffer kept around their surgeries and
+// appointments, so concurrent bookings of the same doctor are serialized.
+func lockAvailableDoctor(tx *gorm.DB, doctorID uint, slot models.TimeSlot, excludeSurgeryID uint) (models.Doctor, error) {
+	var doctor models.Doctor
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", doctorID).
+		First(&doctor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return doctor, fmt.Errorf("doctor %d not found", doctorID)
+		}
+		return doctor, err
+	}
+
+	conflict, err := findDoctorConflict(tx, doctorID, slot.Start, slot.End, excludeSurgeryID)
+	if err != nil {
+		return doctor, err
+	}
+	if conflict != nil {
+		return doctor, fmt.Errorf("%w: doctor %d (%s) already has a surgery from %s to %s (including %s buffer)",
+			errDoctorUnavailable, doctor.ID, doctor.Name, conflict.ScheduledAt.Format(time.RFC3339), conflict.ScheduledEnd.Format(time.RFC3339), config.DoctorBufferTime())
+	}
+
+	buffer := config.DoctorBufferTime()
+	appointment, err := findAppointmentConflict(tx, doctorID, slot.Start.Add(-buffer), slot.End.Add(buffer), 0)
+	if err != nil {
+		return doctor, err
+	}
+	if appointment != nil {
+		return doctor, fmt.Errorf("%w: doctor %d (%s) has appointment %d from %s to %s (including %s buffer)",
+			errDoctorUnavailable, doctor.ID, doctor.Name, appointment.ID, appointment.ScheduledAt.Format(time.RFC3339), appointment.ScheduledEnd.Format(time.RFC3339), buffer)
+	}
+
+	if err := checkDoctorRostered(tx, doctor, slot); err != nil {
+		return doctor, err
+	}
+	return doctor, nil
+}
+
+func parseTimeWindow(startStr, endStr string) (time.Time, time.Time, error) {
+	start := time.Now()
+	if startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid start time. Use RFC3339, e.g. 2026-01-02T09:00:00+05:30")
+		}
+		start = parsed
+	}
+
+	end := start
+	if endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid end time. Use RFC3339, e.g. 2026-01-02T11:00:00+05:30")
+		}
+		end = parsed
+	}
+
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, errors.New("end time must not be before start time")
+	}
+	return start, end, nil
+}
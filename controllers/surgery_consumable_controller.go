@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AddSurgeryConsumable(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("AddSurgeryConsumable: Request received for surgery ID %s", surgeryID)
+
+	var surgery models.SurgerySchedule
+	if err := config.DB.First(&surgery, "id = ?", surgeryID).Error; err != nil {
+		log.Printf("AddSurgeryConsumable: Surgery not found with ID %s", surgeryID)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Surgery not found!"})
+		return
+	}
+
+	if surgery.Status != models.SurgeryStatusScheduled && surgery.Status != models.SurgeryStatusInProgress {
+		log.Printf("AddSurgeryConsumable: Surgery %s is %s, consumables are closed", surgeryID, surgery.Status)
+		c.JSON(http.StatusConflict, gin.H{"error": "Consumables can only be added before the surgery is completed"})
+		return
+	}
+
+	var input models.SurgeryConsumable
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("AddSurgeryConsumable: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.SurgeryScheduleID = surgery.ID
+	config.DB.Create(&input)
+
+	log.Printf("AddSurgeryConsumable: Consumable %d added to surgery %d", input.ID, surgery.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func GetSurgeryConsumables(c *gin.Context) {
+	log.Printf("GetSurgeryConsumables: Request received for surgery ID %s", c.Param("id"))
+
+	var consumables []models.SurgeryConsumable
+
+	if err := config.DB.Where("surgery_schedule_id = ?", c.Param("id")).Find(&consumables).Error; err != nil {
+		log.Printf("GetSurgeryConsumables: Error fetching consumables - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetSurgeryConsumables: Found %d consumables for surgery %s", len(consumables), c.Param("id"))
+	c.JSON(http.StatusOK, consumables)
+}
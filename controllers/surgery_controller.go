@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"errors"
+	"io"
 	"log"
 	"net/http"
 	"time"
@@ -25,209 +26,348 @@ func ScheduleSurgery(c *gin.Context) {
 		return
 	}
 
-	log.Printf("ScheduleSurgery: Scheduling surgery for patient_id=%d, doctor_id=%d", request.PatientID, request.DoctorID)
+	surgeryType, err := prepareSurgeryRequest(config.DB, &request)
+	if err != nil {
+		log.Printf("ScheduleSurgery: Invalid request - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("ScheduleSurgery: Scheduling %s surgery for patient_id=%d, doctor_id=%d", surgeryType.Code, request.PatientID, request.DoctorID)
 
 	var surgery models.SurgerySchedule
+	var displaced []models.SurgerySchedule
 
-	err := config.DB.Transaction(func(tx *gorm.DB) error {
-		var ot models.OperatingTheater
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("status = ?", models.OTStatusAvailable).
-			First(&ot).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				log.Println("ScheduleSurgery: No available Operating Theater found")
-				return errors.New("no available Operating Theater found")
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		surgery, displaced, err = bookSurgery(tx, request, surgeryType, true)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("ScheduleSurgery: Transaction failed - %v", err)
+		if request.Waitlist && isRetryableBookingError(err) {
+			entry, waitlistErr := addToWaitlist(config.DB, request, err.Error())
+			if waitlistErr == nil {
+				log.Printf("ScheduleSurgery: Request queued as waitlist entry %d", entry.ID)
+				c.JSON(http.StatusAccepted, gin.H{
+					"message":        "No slot available, the request was added to the waitlist",
+					"details":        err.Error(),
+					"waitlist_entry": entry,
+				})
+				return
 			}
-			return err
+			log.Printf("ScheduleSurgery: Failed to queue request on the waitlist - %v", waitlistErr)
 		}
-		log.Printf("ScheduleSurgery: Found available OT with ID %d", ot.ID)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to schedule surgery",
+			"details": err.Error(),
+		})
+		return
+	}
+	config.DB.Preload("Patient").Preload("Doctor").Preload("OperatingTheater").Preload("Team.Doctor").Preload("EquipmentBookings.Equipment").First(&surgery, surgery.ID)
 
-		ot.Status = models.OTStatusOccupied
-		if err := tx.Save(&ot).Error; err != nil {
-			log.Printf("ScheduleSurgery: Failed to update OT status - %v", err)
-			return errors.New("failed to update Operating Theater status")
-		}
+	response := gin.H{
+		"message": "Surgery scheduled successfully",
+		"surgery": surgery,
+	}
+	if len(displaced) > 0 {
+		response["displaced"] = displaced
+	}
 
-		var doctor models.Doctor
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("id = ?", request.DoctorID).
-			First(&doctor).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				log.Printf("ScheduleSurgery: Doctor not found with ID %d", request.DoctorID)
-				return errors.New("doctor not found")
-			}
-			return err
-		}
+	log.Printf("ScheduleSurgery: Surgery scheduled successfully with ID %d, displacing %d", surgery.ID, len(displaced))
+	c.JSON(http.StatusCreated, response)
+}
 
-		surgeryDate := request.ScheduledAt.Truncate(24 * time.Hour)
-		nextDay := surgeryDate.Add(24 * time.Hour)
+func CompleteSurgery(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("CompleteSurgery: Request received for surgery ID %s", surgeryID)
 
-		var existingSurgery models.SurgerySchedule
-		err := tx.Where("doctor_id = ? AND scheduled_at >= ? AND scheduled_at < ? AND status NOT IN ?",
-			request.DoctorID, surgeryDate, nextDay,
-			[]models.SurgeryStatus{models.SurgeryStatusCompleted, models.SurgeryStatusCancelled}).
-			First(&existingSurgery).Error
+	var input models.OperativeReportRequest
 
-		if err == nil {
-			log.Printf("ScheduleSurgery: Doctor %d already has surgery on %s", request.DoctorID, surgeryDate.Format("2006-01-02"))
-			return errors.New("doctor already has a surgery scheduled on this date")
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
-		}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("CompleteSurgery: Invalid operative report - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateOperativeReport(input); err != nil {
+		log.Printf("CompleteSurgery: Invalid operative report - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		var patient models.Patient
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("id = ?", request.PatientID).
-			First(&patient).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				log.Printf("ScheduleSurgery: Patient not found with ID %d", request.PatientID)
-				return errors.New("patient not found")
-			}
-			return err
-		}
+	var invoice models.Invoice
+	var report models.OperativeReport
 
-		if patient.Deposit < request.DepositRequired {
-			log.Printf("ScheduleSurgery: Insufficient deposit for patient %d (has %.2f, needs %.2f)", request.PatientID, patient.Deposit, request.DepositRequired)
-			return errors.New("insufficient patient deposit for surgery")
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		surgery, err := transitionSurgery(tx, surgeryID, models.SurgeryStatusCompleted)
+		if err != nil {
+			log.Printf("CompleteSurgery: Cannot complete surgery %s - %v", surgeryID, err)
+			return err
 		}
 
-		patient.Deposit -= request.DepositRequired
-		if err := tx.Save(&patient).Error; err != nil {
-			log.Printf("ScheduleSurgery: Failed to deduct patient deposit - %v", err)
-			return errors.New("failed to deduct patient deposit")
+		report, err = recordOperativeReport(tx, &surgery, input)
+		if err != nil {
+			log.Printf("CompleteSurgery: Failed to record operative report - %v", err)
+			return err
 		}
 
-		surgery = models.SurgerySchedule{
-			PatientID:          request.PatientID,
-			DoctorID:           request.DoctorID,
-			OperatingTheaterID: ot.ID,
-			SurgeryType:        request.SurgeryType,
-			ScheduledAt:        request.ScheduledAt,
-			EstimatedDuration:  request.EstimatedDuration,
-			DepositDeducted:    request.DepositRequired,
-			Status:             models.SurgeryStatusScheduled,
-			Notes:              request.Notes,
+		if err := releaseOperatingTheater(tx, surgery.OperatingTheaterID); err != nil {
+			log.Printf("CompleteSurgery: Failed to release OT %d - %v", surgery.OperatingTheaterID, err)
+			return errors.New("failed to update Operating Theater status")
 		}
 
-		if err := tx.Create(&surgery).Error; err != nil {
-			log.Printf("ScheduleSurgery: Failed to create surgery schedule - %v", err)
-			return errors.New("failed to create surgery schedule")
+		invoice, err = generateSurgeryInvoice(tx, surgery)
+		if err != nil {
+			log.Printf("CompleteSurgery: Failed to generate invoice - %v", err)
+			return errors.New("failed to generate invoice")
 		}
 
 		return nil
 	})
 
 	if err != nil {
-		log.Printf("ScheduleSurgery: Transaction failed - %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Failed to schedule surgery",
-			"details": err.Error(),
-		})
+		log.Printf("CompleteSurgery: Transaction failed - %v", err)
+		c.JSON(surgeryErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	config.DB.Preload("Patient").Preload("Doctor").Preload("OperatingTheater").First(&surgery, surgery.ID)
+	config.DB.Preload("Lines").First(&invoice, invoice.ID)
 
-	log.Printf("ScheduleSurgery: Surgery scheduled successfully with ID %d", surgery.ID)
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Surgery scheduled successfully",
-		"surgery": surgery,
+	log.Printf("CompleteSurgery: Surgery %s completed successfully in %d minutes (estimated %d), invoice %d outstanding %s",
+		surgeryID, report.ActualDuration, report.EstimatedDuration, invoice.ID, invoice.Outstanding)
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Surgery completed successfully",
+		"invoice":          invoice,
+		"operative_report": report,
 	})
 }
 
-func CompleteSurgery(c *gin.Context) {
+func CancelSurgery(c *gin.Context) {
 	surgeryID := c.Param("id")
-	log.Printf("CompleteSurgery: Request received for surgery ID %s", surgeryID)
+	log.Printf("CancelSurgery: Request received for surgery ID %s", surgeryID)
+
+	var surgery models.SurgerySchedule
+	var freed *models.TimeSlot
 
 	err := config.DB.Transaction(func(tx *gorm.DB) error {
-		var surgery models.SurgerySchedule
+		var current models.SurgerySchedule
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 			Where("id = ?", surgeryID).
-			First(&surgery).Error; err != nil {
-			log.Printf("CompleteSurgery: Surgery not found with ID %s", surgeryID)
-			return errors.New("surgery not found")
-		}
-
-		if surgery.Status != models.SurgeryStatusScheduled && surgery.Status != models.SurgeryStatusInProgress {
-			log.Printf("CompleteSurgery: Surgery %s is already completed or cancelled", surgeryID)
-			return errors.New("surgery is already completed or cancelled")
+			First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errSurgeryNotFound
+			}
+			return err
 		}
-
-		var ot models.OperatingTheater
-		if err := tx.First(&ot, surgery.OperatingTheaterID).Error; err == nil {
-			ot.Status = models.OTStatusAvailable
-			tx.Save(&ot)
-			log.Printf("CompleteSurgery: OT %d marked as available", ot.ID)
+		// A Postponed surgery no longer holds its slot, so there is nothing
+		// to offer to the waitlist.
+		if current.Status == models.SurgeryStatusScheduled {
+			slot := current.Slot()
+			freed = &slot
 		}
 
-		surgery.Status = models.SurgeryStatusCompleted
-		if err := tx.Save(&surgery).Error; err != nil {
-			log.Printf("CompleteSurgery: Failed to update surgery status - %v", err)
-			return errors.New("failed to update surgery status")
+		var err error
+		surgery, err = transitionSurgery(tx, surgeryID, models.SurgeryStatusCancelled)
+		if err != nil {
+			log.Printf("CancelSurgery: Cannot cancel surgery %s - %v", surgeryID, err)
+			return err
 		}
 
-		return nil
+		return settleCancelledSurgery(tx, &surgery, time.Now())
 	})
 
 	if err != nil {
-		log.Printf("CompleteSurgery: Transaction failed - %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		log.Printf("CancelSurgery: Transaction failed - %v", err)
+		c.JSON(surgeryErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("CompleteSurgery: Surgery %s completed successfully", surgeryID)
-	c.JSON(http.StatusOK, gin.H{"message": "Surgery completed successfully"})
+	response := gin.H{
+		"message":             "Surgery cancelled",
+		"cancellation_rule":   surgery.CancellationRule,
+		"cancellation_fee":    surgery.CancellationFee,
+		"cancellation_refund": surgery.CancellationRefund,
+		"currency":            surgery.Currency,
+	}
+	if freed != nil {
+		if entry, err := offerFreedSlot(*freed); err != nil {
+			log.Printf("CancelSurgery: Failed to offer the freed slot to the waitlist - %v", err)
+		} else if entry != nil {
+			response["waitlist_entry"] = entry
+		}
+	}
+
+	log.Printf("CancelSurgery: Surgery %s cancelled under %q, retained %s, refunded %s", surgeryID, surgery.CancellationRule, surgery.CancellationFee, surgery.CancellationRefund)
+	c.JSON(http.StatusOK, response)
 }
 
-func CancelSurgery(c *gin.Context) {
+func RescheduleSurgery(c *gin.Context) {
 	surgeryID := c.Param("id")
-	log.Printf("CancelSurgery: Request received for surgery ID %s", surgeryID)
+	log.Printf("RescheduleSurgery: Request received for surgery ID %s", surgeryID)
+
+	var request models.SurgeryRescheduleRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("RescheduleSurgery: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var surgery models.SurgerySchedule
+	var freed *models.TimeSlot
 
 	err := config.DB.Transaction(func(tx *gorm.DB) error {
-		var surgery models.SurgerySchedule
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 			Where("id = ?", surgeryID).
 			First(&surgery).Error; err != nil {
-			log.Printf("CancelSurgery: Surgery not found with ID %s", surgeryID)
-			return errors.New("surgery not found")
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("RescheduleSurgery: Surgery not found with ID %s", surgeryID)
+				return errSurgeryNotFound
+			}
+			return err
 		}
-
-		if surgery.Status != models.SurgeryStatusScheduled {
-			log.Printf("CancelSurgery: Cannot cancel surgery %s - status is %s", surgeryID, surgery.Status)
-			return errors.New("can only cancel scheduled surgeries")
+		if surgery.Status == models.SurgeryStatusScheduled {
+			previous := surgery.Slot()
+			freed = &previous
 		}
 
-		var ot models.OperatingTheater
-		if err := tx.First(&ot, surgery.OperatingTheaterID).Error; err == nil {
-			ot.Status = models.OTStatusAvailable
-			tx.Save(&ot)
-			log.Printf("CancelSurgery: OT %d marked as available", ot.ID)
+		return rescheduleSurgery(tx, &surgery, request)
+	})
+
+	if err != nil {
+		log.Printf("RescheduleSurgery: Transaction failed - %v", err)
+		c.JSON(surgeryErrorStatus(err), gin.H{
+			"error":   "Failed to reschedule surgery",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").Preload("OperatingTheater").Preload("Team.Doctor").Preload("Reschedules").Preload("EquipmentBookings.Equipment").First(&surgery, surgery.ID)
+
+	response := gin.H{
+		"message": "Surgery rescheduled successfully",
+		"surgery": surgery,
+	}
+	if freed != nil {
+		if entry, err := offerFreedSlot(*freed); err != nil {
+			log.Printf("RescheduleSurgery: Failed to offer the freed slot to the waitlist - %v", err)
+		} else if entry != nil {
+			response["waitlist_entry"] = entry
 		}
+	}
+
+	log.Printf("RescheduleSurgery: Surgery %d moved to %s in OT %d", surgery.ID, surgery.ScheduledAt.Format(time.RFC3339), surgery.OperatingTheaterID)
+	c.JSON(http.StatusOK, response)
+}
 
-		var patient models.Patient
-		if err := tx.First(&patient, surgery.PatientID).Error; err == nil {
-			patient.Deposit += surgery.DepositDeducted
-			tx.Save(&patient)
-			log.Printf("CancelSurgery: Refunded %.2f to patient %d", surgery.DepositDeducted, patient.ID)
+// rescheduleSurgery moves a locked surgery to the time, doctor or theater in
+// request, checking the same conflicts as a new booking, rebooks its
+// equipment and post-op bed and records the change in the reschedule history.
+func rescheduleSurgery(tx *gorm.DB, surgery *models.SurgerySchedule, request models.SurgeryRescheduleRequest) error {
+	history := models.SurgeryReschedule{
+		SurgeryScheduleID:          surgery.ID,
+		PreviousScheduledAt:        surgery.ScheduledAt,
+		PreviousEstimatedDuration:  surgery.EstimatedDuration,
+		PreviousDoctorID:           surgery.DoctorID,
+		PreviousOperatingTheaterID: surgery.OperatingTheaterID,
+		Reason:                     request.Reason,
+	}
+
+	if err := surgery.PrepareReschedule(); err != nil {
+		log.Printf("rescheduleSurgery: Cannot reschedule surgery %d - %v", surgery.ID, err)
+		return err
+	}
+
+	if request.ScheduledAt != nil {
+		surgery.ScheduledAt = *request.ScheduledAt
+	}
+	if request.EstimatedDuration != nil {
+		surgery.EstimatedDuration = *request.EstimatedDuration
+	}
+	if request.DoctorID != nil {
+		surgery.DoctorID = *request.DoctorID
+	}
+	slot := surgery.Slot()
+
+	constraints := theaterConstraints{TheaterID: request.OperatingTheaterID, RequiredEquipment: surgery.RequiredEquipment}
+	if request.OperatingTheaterID == nil {
+		constraints.TheaterID = &surgery.OperatingTheaterID
+	}
+	ot, units, err := selectOperatingTheater(tx, constraints, slot, surgery.ID)
+	if err != nil && request.OperatingTheaterID == nil {
+		log.Printf("rescheduleSurgery: Current OT %d unusable (%v), looking for another", surgery.OperatingTheaterID, err)
+		constraints, err = constraintsForSurgery(tx, *surgery)
+		if err != nil {
+			return err
 		}
+		ot, units, err = selectOperatingTheater(tx, constraints, slot, surgery.ID)
+	}
+	if err != nil {
+		log.Printf("rescheduleSurgery: No suitable Operating Theater - %v", err)
+		return err
+	}
+	surgery.OperatingTheaterID = ot.ID
+
+	if _, err := lockAvailableDoctor(tx, surgery.DoctorID, slot, surgery.ID); err != nil {
+		log.Printf("rescheduleSurgery: Doctor %d unavailable - %v", surgery.DoctorID, err)
+		return err
+	}
 
-		surgery.Status = models.SurgeryStatusCancelled
-		if err := tx.Save(&surgery).Error; err != nil {
-			log.Printf("CancelSurgery: Failed to update surgery status - %v", err)
-			return errors.New("failed to update surgery status")
+	if request.DoctorID != nil && *request.DoctorID != history.PreviousDoctorID {
+		var surgeryType models.SurgeryType
+		if err := tx.Where("code = ?", surgery.SurgeryType).First(&surgeryType).Error; err == nil {
+			if err := checkDoctorCredentials(tx, surgery.DoctorID, surgeryType); err != nil {
+				log.Printf("rescheduleSurgery: Doctor %d not qualified - %v", surgery.DoctorID, err)
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
 		}
+	}
 
-		return nil
-	})
+	var team []models.SurgicalTeamMember
+	if err := tx.Where("surgery_schedule_id = ?", surgery.ID).Find(&team).Error; err != nil {
+		return err
+	}
+	if err := validateSurgicalTeam(tx, surgery.DoctorID, teamRequests(team), slot, surgery.ID); err != nil {
+		log.Printf("rescheduleSurgery: Surgical team unavailable - %v", err)
+		return err
+	}
 
-	if err != nil {
-		log.Printf("CancelSurgery: Transaction failed - %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if _, err := lockPatient(tx, surgery.PatientID); err != nil {
+		return err
+	}
+	if err := checkPatientFree(tx, surgery.PatientID, slot, surgery.ID, 0); err != nil {
+		log.Printf("rescheduleSurgery: Patient %d unavailable - %v", surgery.PatientID, err)
+		return err
 	}
 
-	log.Printf("CancelSurgery: Surgery %s cancelled and deposit refunded", surgeryID)
-	c.JSON(http.StatusOK, gin.H{"message": "Surgery cancelled and deposit refunded"})
+	if err := tx.Omit(clause.Associations).Save(surgery).Error; err != nil {
+		log.Printf("rescheduleSurgery: Failed to update surgery - %v", err)
+		return errors.New("failed to update surgery schedule")
+	}
+
+	if err := bookEquipment(tx, *surgery, units); err != nil {
+		log.Printf("rescheduleSurgery: Failed to rebook equipment - %v", err)
+		return errors.New("failed to rebook equipment")
+	}
+
+	if err := reservePostOpBed(tx, surgery); err != nil {
+		return err
+	}
+
+	history.NewScheduledAt = surgery.ScheduledAt
+	history.NewEstimatedDuration = surgery.EstimatedDuration
+	history.NewDoctorID = surgery.DoctorID
+	history.NewOperatingTheaterID = surgery.OperatingTheaterID
+	if err := tx.Create(&history).Error; err != nil {
+		log.Printf("rescheduleSurgery: Failed to record reschedule history - %v", err)
+		return errors.New("failed to record reschedule history")
+	}
+
+	return nil
 }
 
 func GetSurgeryByID(c *gin.Context) {
@@ -235,13 +375,14 @@ func GetSurgeryByID(c *gin.Context) {
 
 	var surgery models.SurgerySchedule
 
-	if err := config.DB.Preload("Patient").Preload("Doctor").Preload("OperatingTheater").
+	if err := config.DB.Preload("Patient").Preload("Doctor").Preload("OperatingTheater").Preload("Team.Doctor").Preload("Reschedules").Preload("EquipmentBookings.Equipment").Preload("Checklist", orderedChecklist).Preload("OperativeReport").Preload("PostOpBed.Bed").
 		Where("id = ?", c.Param("id")).
 		First(&surgery).Error; err != nil {
 		log.Printf("GetSurgeryByID: Surgery not found with ID %s", c.Param("id"))
 		c.JSON(http.StatusNotFound, gin.H{"error": "Surgery not found!"})
 		return
 	}
+	surgery.OutstandingChecklist = surgery.OutstandingChecklistItems()
 
 	log.Printf("GetSurgeryByID: Surgery found with ID %d", surgery.ID)
 	c.JSON(http.StatusOK, surgery)
@@ -268,8 +409,8 @@ func GetSurgeriesByDoctor(c *gin.Context) {
 
 	var surgeries []models.SurgerySchedule
 
-	if err := config.DB.Preload("Patient").Preload("OperatingTheater").
-		Where("doctor_id = ?", c.Param("doctor_id")).
+	if err := involvingDoctor(config.DB.Preload("Patient").Preload("OperatingTheater").Preload("Team.Doctor"), c.Param("doctor_id")).
+		Order("scheduled_at").
 		Find(&surgeries).Error; err != nil {
 		log.Printf("GetSurgeriesByDoctor: Error fetching surgeries - %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errSurgeryNotFound = errors.New("surgery not found")
+
+// transitionSurgery locks the surgery row, applies the state machine and
+// saves the result. Handlers add their side effects on the returned surgery.
+func transitionSurgery(tx *gorm.DB, surgeryID string, next models.SurgeryStatus) (models.SurgerySchedule, error) {
+	var surgery models.SurgerySchedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", surgeryID).
+		First(&surgery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return surgery, errSurgeryNotFound
+		}
+		return surgery, err
+	}
+
+	if err := surgery.TransitionTo(next, time.Now()); err != nil {
+		return surgery, err
+	}
+
+	if err := tx.Save(&surgery).Error; err != nil {
+		log.Printf("transitionSurgery: Failed to save surgery %d - %v", surgery.ID, err)
+		return surgery, errors.New("failed to update surgery status")
+	}
+	return surgery, nil
+}
+
+// occupyOperatingTheater marks the theater of a starting surgery Occupied. A
+// theater under maintenance or already running another surgery is refused.
+func occupyOperatingTheater(tx *gorm.DB, otID uint) error {
+	var ot models.OperatingTheater
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ot, otID).Error; err != nil {
+		return err
+	}
+	if ot.Status != models.OTStatusAvailable {
+		return fmt.Errorf("%w: operating theater %d is %s", errTheaterInUse, ot.ID, ot.Status)
+	}
+	return tx.Model(&ot).Update("status", models.OTStatusOccupied).Error
+}
+
+// releaseOperatingTheater makes the theater of a finished surgery Available
+// again, unless its status was changed while the surgery ran, e.g. to
+// Maintenance.
+func releaseOperatingTheater(tx *gorm.DB, otID uint) error {
+	return tx.Model(&models.OperatingTheater{}).
+		Where("id = ? AND status = ?", otID, models.OTStatusOccupied).
+		Update("status", models.OTStatusAvailable).Error
+}
+
+func surgeryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errSurgeryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrInvalidSurgeryTransition), errors.Is(err, errChecklistIncomplete), errors.Is(err, errTheaterInUse):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func StartSurgery(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("StartSurgery: Request received for surgery ID %s", surgeryID)
+
+	var surgery models.SurgerySchedule
+	var checklistWaived string
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		surgery, err = transitionSurgery(tx, surgeryID, models.SurgeryStatusInProgress)
+		if err != nil {
+			log.Printf("StartSurgery: Cannot start surgery %s - %v", surgeryID, err)
+			return err
+		}
+
+		if err := checkSurgeryReadiness(tx, surgery.ID); err != nil {
+			if !errors.Is(err, errChecklistIncomplete) || !surgery.Priority.WaivesChecklist() {
+				log.Printf("StartSurgery: Surgery %s not ready - %v", surgeryID, err)
+				return err
+			}
+			log.Printf("StartSurgery: %s surgery %s starts with the checklist open - %v", surgery.Priority, surgeryID, err)
+			checklistWaived = err.Error()
+		}
+
+		if err := occupyOperatingTheater(tx, surgery.OperatingTheaterID); err != nil {
+			log.Printf("StartSurgery: Failed to occupy OT %d - %v", surgery.OperatingTheaterID, err)
+			if errors.Is(err, errTheaterInUse) {
+				return err
+			}
+			return errors.New("failed to update Operating Theater status")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("StartSurgery: Transaction failed - %v", err)
+		c.JSON(surgeryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"message": "Surgery started successfully",
+		"surgery": surgery,
+	}
+	if checklistWaived != "" {
+		response["checklist_waived"] = checklistWaived
+	}
+
+	log.Printf("StartSurgery: Surgery %s started at %s", surgeryID, surgery.ActualStartAt.Format(time.RFC3339))
+	c.JSON(http.StatusOK, response)
+}
+
+func PostponeSurgery(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("PostponeSurgery: Request received for surgery ID %s", surgeryID)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := transitionSurgery(tx, surgeryID, models.SurgeryStatusPostponed)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("PostponeSurgery: Transaction failed - %v", err)
+		c.JSON(surgeryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("PostponeSurgery: Surgery %s postponed, deposit kept on hold", surgeryID)
+	c.JSON(http.StatusOK, gin.H{"message": "Surgery postponed, deposit kept on hold"})
+}
+
+func MarkSurgeryNoShow(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("MarkSurgeryNoShow: Request received for surgery ID %s", surgeryID)
+
+	var surgery models.SurgerySchedule
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		surgery, err = transitionSurgery(tx, surgeryID, models.SurgeryStatusNoShow)
+		if err != nil {
+			return err
+		}
+		return settleNoShowSurgery(tx, &surgery)
+	})
+
+	if err != nil {
+		log.Printf("MarkSurgeryNoShow: Transaction failed - %v", err)
+		c.JSON(surgeryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("MarkSurgeryNoShow: Surgery %s marked as no-show, captured %s", surgeryID, surgery.CancellationFee)
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Surgery marked as no-show",
+		"cancellation_fee": surgery.CancellationFee,
+		"currency":         config.Currency(),
+	})
+}
+
+// settleNoShowSurgery captures the whole deposit held for a surgery the
+// patient did not turn up for, so the hold does not stay open forever.
+func settleNoShowSurgery(tx *gorm.DB, surgery *models.SurgerySchedule) error {
+	held, err := depositHeldForSurgery(tx, surgery.ID)
+	if err != nil {
+		return err
+	}
+	if held <= 0 {
+		return nil
+	}
+
+	patient, err := lockPatient(tx, surgery.PatientID)
+	if err != nil {
+		log.Printf("settleNoShowSurgery: Patient %d not found - %v", surgery.PatientID, err)
+		return errors.New("patient not found")
+	}
+	if _, err := recordDepositTransaction(tx, &patient, models.DepositCapture, held, &surgery.ID, "Deposit forfeited: patient did not show"); err != nil {
+		log.Printf("settleNoShowSurgery: Failed to capture deposit - %v", err)
+		return errors.New("failed to capture deposit")
+	}
+
+	surgery.CancellationRule = "No-show"
+	surgery.CancellationFee = held
+	if err := tx.Omit(clause.Associations).Save(surgery).Error; err != nil {
+		log.Printf("settleNoShowSurgery: Failed to record no-show fee on surgery %d - %v", surgery.ID, err)
+		return errors.New("failed to update surgery status")
+	}
+	return nil
+}
+
+// settleCancelledSurgery applies the cancellation policy of the surgery type
+// to the deposit held for a surgery that has just been cancelled: the fee is
+// captured, the rest refunded, and both are recorded on the surgery.
+func settleCancelledSurgery(tx *gorm.DB, surgery *models.SurgerySchedule, at time.Time) error {
+	rules, err := cancellationRulesFor(tx, surgery.SurgeryType)
+	if err != nil {
+		return err
+	}
+
+	held, err := depositHeldForSurgery(tx, surgery.ID)
+	if err != nil {
+		return err
+	}
+
+	refundPercent := 100
+	surgery.CancellationRule = "No cancellation policy configured"
+	if len(rules) > 0 {
+		hoursBefore := surgery.ScheduledAt.Sub(at).Hours()
+		if rule := models.SelectCancellationRule(rules, hoursBefore); rule != nil {
+			refundPercent = rule.RefundPercent
+			surgery.CancellationRule = rule.Name
+		} else {
+			refundPercent = 0
+			surgery.CancellationRule = "Too late for any refund"
+		}
+	}
+
+	surgery.CancelledAt = &at
+	surgery.CancellationRefund = held.Percent(refundPercent)
+	surgery.CancellationFee = held - surgery.CancellationRefund
+
+	if held > 0 {
+		patient, err := lockPatient(tx, surgery.PatientID)
+		if err != nil {
+			log.Printf("settleCancelledSurgery: Patient %d not found for refund - %v", surgery.PatientID, err)
+			return errors.New("patient not found")
+		}
+		if surgery.CancellationFee > 0 {
+			if _, err := recordDepositTransaction(tx, &patient, models.DepositCapture, surgery.CancellationFee, &surgery.ID, "Cancellation fee: "+surgery.CancellationRule); err != nil {
+				log.Printf("settleCancelledSurgery: Failed to capture cancellation fee - %v", err)
+				return errors.New("failed to capture cancellation fee")
+			}
+		}
+		if surgery.CancellationRefund > 0 {
+			if _, err := recordDepositTransaction(tx, &patient, models.DepositRefund, surgery.CancellationRefund, &surgery.ID, "Surgery cancelled"); err != nil {
+				log.Printf("settleCancelledSurgery: Failed to refund deposit - %v", err)
+				return errors.New("failed to refund patient deposit")
+			}
+		}
+	}
+
+	if err := tx.Omit(clause.Associations).Save(surgery).Error; err != nil {
+		log.Printf("settleCancelledSurgery: Failed to record cancellation on surgery %d - %v", surgery.ID, err)
+		return errors.New("failed to update surgery status")
+	}
+	return nil
+}
+
+// cancellationRulesFor returns the rules of the surgery type, falling back to
+// the default rules when the type has none or is not in the catalog.
+func cancellationRulesFor(tx *gorm.DB, surgeryTypeCode string) ([]models.CancellationPolicyRule, error) {
+	var rules []models.CancellationPolicyRule
+
+	var surgeryType models.SurgeryType
+	err := tx.Where("code = ?", surgeryTypeCode).First(&surgeryType).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		if err := tx.Where("surgery_type_id = ?", surgeryType.ID).Find(&rules).Error; err != nil {
+			return nil, err
+		}
+		if len(rules) > 0 {
+			return rules, nil
+		}
+	}
+
+	if err := tx.Where("surgery_type_id IS NULL").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// preemptOperatingTheater frees a theater for a surgery of the given priority
+// by postponing the lower-priority surgeries booked in it during slot. It
+// picks the candidate that displaces the fewest surgeries. Surgeries already
+// under way and maintenance windows are never preempted.
+func preemptOperatingTheater(tx *gorm.DB, c theaterConstraints, slot models.TimeSlot, priority models.SurgeryPriority) (models.OperatingTheater, []models.Equipment, []models.SurgerySchedule, error) {
+	matching, err := candidateTheaters(tx, c)
+	if err != nil {
+		return models.OperatingTheater{}, nil, nil, err
+	}
+
+	best := -1
+	var displaced []models.SurgerySchedule
+	for i, ot := range matching {
+		turnover := ot.Turnover(config.OTTurnoverTime())
+
+		var maintenance int64
+		if err := overlappingMaintenance(tx.Model(&models.OTMaintenanceWindow{}), ot.ID, slot.Start, slot.End, turnover).
+			Count(&maintenance).Error; err != nil {
+			return models.OperatingTheater{}, nil, nil, err
+		}
+		if maintenance > 0 {
+			continue
+		}
+
+		var booked []models.SurgerySchedule
+		if err := overlappingSurgeries(tx.Clauses(clause.Locking{Strength: "UPDATE"}), slot.Start.Add(-turnover), slot.End.Add(turnover)).
+			Where("operating_theater_id = ?", ot.ID).
+			Find(&booked).Error; err != nil {
+			return models.OperatingTheater{}, nil, nil, err
+		}
+
+		preemptable := true
+		for _, surgery := range booked {
+			if surgery.Status != models.SurgeryStatusScheduled || !priority.Preempts(surgery.Priority) {
+				preemptable = false
+				break
+			}
+		}
+		if preemptable && (best < 0 || len(booked) < len(displaced)) {
+			best = i
+			displaced = booked
+		}
+	}
+	if best < 0 {
+		return models.OperatingTheater{}, nil, nil, fmt.Errorf("%w: no operating theater can be freed for a %s surgery between %s and %s",
+			errNoOperatingTheater, priority, slot.Start.Format(time.RFC3339), slot.End.Format(time.RFC3339))
+	}
+	ot := matching[best]
+
+	now := time.Now()
+	for i := range displaced {
+		if err := displaced[i].TransitionTo(models.SurgeryStatusPostponed, now); err != nil {
+			return ot, nil, nil, err
+		}
+		if err := tx.Omit(clause.Associations).Save(&displaced[i]).Error; err != nil {
+			return ot, nil, nil, err
+		}
+		log.Printf("preemptOperatingTheater: Surgery %d bumped from OT %d", displaced[i].ID, ot.ID)
+	}
+
+	units, err := reserveMovableEquipment(tx, missingEquipment(ot, c.RequiredEquipment), slot, 0)
+	if err != nil {
+		return ot, nil, nil, fmt.Errorf("operating theater %d was freed but %v", ot.ID, err)
+	}
+	return ot, units, displaced, nil
+}
+
+// checkDisplacedSurgeryStaff makes sure the doctor and team of a bumped
+// surgery are still free at its time. The surgery was Postponed while the
+// preempting surgery was checked, so its doctor may have been booked there.
+func checkDisplacedSurgeryStaff(tx *gorm.DB, surgery models.SurgerySchedule) error {
+	if _, err := lockAvailableDoctor(tx, surgery.DoctorID, surgery.Slot(), surgery.ID); err != nil {
+		return err
+	}
+
+	var team []models.SurgicalTeamMember
+	if err := tx.Where("surgery_schedule_id = ?", surgery.ID).Find(&team).Error; err != nil {
+		return err
+	}
+	return validateSurgicalTeam(tx, surgery.DoctorID, teamRequests(team), surgery.Slot(), surgery.ID)
+}
+
+// relocateDisplacedSurgeries tries to move each surgery bumped by preempting
+// into another theater at the same time, with its doctor and team still free
+// and its post-op bed held again. Surgeries that cannot be moved stay
+// Postponed, flagged with the surgery that displaced them. The doctors of
+// every displaced surgery are notified either way.
+func relocateDisplacedSurgeries(tx *gorm.DB, preempting models.SurgerySchedule, displaced []models.SurgerySchedule) ([]models.SurgerySchedule, error) {
+	for i := range displaced {
+		surgery := &displaced[i]
+		surgery.DisplacedBySurgeryID = &preempting.ID
+		previousOT := surgery.OperatingTheaterID
+
+		constraints, err := constraintsForSurgery(tx, *surgery)
+		if err != nil {
+			return nil, err
+		}
+
+		var message string
+		ot, units, err := selectOperatingTheater(tx, constraints, surgery.Slot(), surgery.ID)
+		if err == nil {
+			err = checkDisplacedSurgeryStaff(tx, *surgery)
+		}
+		if err == nil {
+			err = checkPatientFree(tx, surgery.PatientID, surgery.Slot(), surgery.ID, 0)
+		}
+		if err == nil {
+			// The bed was released while the surgery was Postponed and the
+			// preempting surgery may have taken it.
+			err = reservePostOpBed(tx, surgery)
+		}
+		if err == nil {
+			if err := surgery.PrepareReschedule(); err != nil {
+				return nil, err
+			}
+			surgery.OperatingTheaterID = ot.ID
+			if err := tx.Omit(clause.Associations).Save(surgery).Error; err != nil {
+				return nil, err
+			}
+			if err := bookEquipment(tx, *surgery, units); err != nil {
+				return nil, err
+			}
+			history := models.SurgeryReschedule{
+				SurgeryScheduleID:          surgery.ID,
+				PreviousScheduledAt:        surgery.ScheduledAt,
+				PreviousEstimatedDuration:  surgery.EstimatedDuration,
+				PreviousDoctorID:           surgery.DoctorID,
+				PreviousOperatingTheaterID: previousOT,
+				NewScheduledAt:             surgery.ScheduledAt,
+				NewEstimatedDuration:       surgery.EstimatedDuration,
+				NewDoctorID:                surgery.DoctorID,
+				NewOperatingTheaterID:      ot.ID,
+				Reason:                     fmt.Sprintf("Moved to make room for %s surgery %d", preempting.Priority, preempting.ID),
+			}
+			if err := tx.Create(&history).Error; err != nil {
+				return nil, err
+			}
+			message = fmt.Sprintf("Surgery %d at %s was moved from operating theater %d to %d to make room for %s surgery %d.",
+				surgery.ID, surgery.ScheduledAt.Format(time.RFC3339), previousOT, ot.ID, preempting.Priority, preempting.ID)
+		} else {
+			if err := tx.Omit(clause.Associations).Save(surgery).Error; err != nil {
+				return nil, err
+			}
+			message = fmt.Sprintf("Surgery %d at %s was postponed to make room for %s surgery %d and needs to be rescheduled: %v.",
+				surgery.ID, surgery.ScheduledAt.Format(time.RFC3339), preempting.Priority, preempting.ID, err)
+		}
+
+		log.Printf("relocateDisplacedSurgeries: %s", message)
+		if err := notifySurgeryDoctors(tx, *surgery, message); err != nil {
+			return nil, err
+		}
+	}
+	return displaced, nil
+}
@@ -0,0 +1,330 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errSeriesNotFound = errors.New("surgery series not found")
+
+func orderedStages(db *gorm.DB) *gorm.DB {
+	return db.Order("series_stage")
+}
+
+// expandSeriesStages turns a repeat into one stage per occurrence.
+func expandSeriesStages(request models.SurgerySeriesRequest) ([]models.SurgeryScheduleRequest, error) {
+	if request.Repeat == nil {
+		return request.Stages, nil
+	}
+	if len(request.Stages) != 1 {
+		return nil, errors.New("repeat needs exactly one stage to repeat")
+	}
+
+	stages := make([]models.SurgeryScheduleRequest, 0, request.Repeat.Count)
+	for i := 0; i < request.Repeat.Count; i++ {
+		stage := request.Stages[0]
+		stage.ScheduledAt = stage.ScheduledAt.AddDate(0, 0, i*request.Repeat.IntervalDays)
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// lockSeriesStages locks the series and its stages that have not started
+// yet, in stage order.
+func lockSeriesStages(tx *gorm.DB, seriesID string) (models.SurgerySeries, []models.SurgerySchedule, error) {
+	var series models.SurgerySeries
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&series, "id = ?", seriesID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return series, nil, errSeriesNotFound
+		}
+		return series, nil, err
+	}
+
+	var stages []models.SurgerySchedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("surgery_series_id = ? AND status IN ?", series.ID, []models.SurgeryStatus{models.SurgeryStatusScheduled, models.SurgeryStatusPostponed}).
+		Order("series_stage").
+		Find(&stages).Error; err != nil {
+		return series, nil, err
+	}
+	if len(stages) == 0 {
+		return series, nil, fmt.Errorf("%w: surgery series %d has no stage left to change", models.ErrInvalidSurgeryTransition, series.ID)
+	}
+	return series, stages, nil
+}
+
+func seriesErrorStatus(err error) int {
+	if errors.Is(err, errSeriesNotFound) {
+		return http.StatusNotFound
+	}
+	return surgeryErrorStatus(err)
+}
+
+// offerFreedSlots hands the slots released by a series change to the
+// waitlist, returning the entries that got one.
+func offerFreedSlots(handler string, freed []models.TimeSlot) []*models.WaitlistEntry {
+	entries := []*models.WaitlistEntry{}
+	for _, slot := range freed {
+		entry, err := offerFreedSlot(slot)
+		if err != nil {
+			log.Printf("%s: Failed to offer the freed slot to the waitlist - %v", handler, err)
+			continue
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func ScheduleSurgerySeries(c *gin.Context) {
+	log.Println("ScheduleSurgerySeries: Request received")
+
+	var request models.SurgerySeriesRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("ScheduleSurgerySeries: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stages, err := expandSeriesStages(request)
+	if err != nil {
+		log.Printf("ScheduleSurgerySeries: Invalid request - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, stage := range stages[1:] {
+		if stage.PatientID != stages[0].PatientID {
+			log.Println("ScheduleSurgerySeries: Stages for different patients")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "all stages of a series must be for the same patient"})
+			return
+		}
+	}
+
+	series := models.SurgerySeries{
+		PatientID: stages[0].PatientID,
+		Name:      request.Name,
+		Notes:     request.Notes,
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&series).Error; err != nil {
+			log.Printf("ScheduleSurgerySeries: Failed to create series - %v", err)
+			return errors.New("failed to create surgery series")
+		}
+
+		var previousEnd time.Time
+		for i := range stages {
+			stage := stages[i]
+			surgeryType, err := prepareSurgeryRequest(tx, &stage)
+			if err != nil {
+				return fmt.Errorf("stage %d: %w", i+1, err)
+			}
+			if stage.ScheduledAt.Before(previousEnd) {
+				return fmt.Errorf("stage %d starts before stage %d ends", i+1, i)
+			}
+			previousEnd = stage.ScheduledAt.Add(time.Duration(stage.EstimatedDuration) * time.Minute)
+
+			surgery, _, err := bookSurgery(tx, stage, surgeryType, false)
+			if err != nil {
+				log.Printf("ScheduleSurgerySeries: Stage %d does not fit - %v", i+1, err)
+				return fmt.Errorf("stage %d: %w", i+1, err)
+			}
+			if err := tx.Model(&models.SurgerySchedule{}).Where("id = ?", surgery.ID).
+				Updates(map[string]interface{}{"surgery_series_id": series.ID, "series_stage": i + 1}).Error; err != nil {
+				log.Printf("ScheduleSurgerySeries: Failed to link surgery %d - %v", surgery.ID, err)
+				return errors.New("failed to link surgery to series")
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("ScheduleSurgerySeries: Transaction failed - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to schedule surgery series, no stage was booked",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Stages", orderedStages).Preload("Stages.OperatingTheater").First(&series, series.ID)
+
+	log.Printf("ScheduleSurgerySeries: Series %d scheduled with %d stages", series.ID, len(stages))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Surgery series scheduled successfully",
+		"series":  series,
+	})
+}
+
+func GetSurgerySeriesByID(c *gin.Context) {
+	log.Printf("GetSurgerySeriesByID: Request received for ID %s", c.Param("id"))
+
+	var series models.SurgerySeries
+
+	if err := config.DB.Preload("Patient").Preload("Stages", orderedStages).Preload("Stages.Doctor").Preload("Stages.OperatingTheater").
+		First(&series, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GetSurgerySeriesByID: Series not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Surgery series not found!"})
+		return
+	}
+
+	log.Printf("GetSurgerySeriesByID: Found series %d with %d stages", series.ID, len(series.Stages))
+	c.JSON(http.StatusOK, series)
+}
+
+func GetPatientSurgerySeries(c *gin.Context) {
+	log.Printf("GetPatientSurgerySeries: Request received for patient ID %s", c.Param("id"))
+
+	var series []models.SurgerySeries
+
+	if err := config.DB.Preload("Stages", orderedStages).Where("patient_id = ?", c.Param("id")).Order("id").Find(&series).Error; err != nil {
+		log.Printf("GetPatientSurgerySeries: Error fetching series - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetPatientSurgerySeries: Found %d series for patient %s", len(series), c.Param("id"))
+	c.JSON(http.StatusOK, series)
+}
+
+func CancelSurgerySeries(c *gin.Context) {
+	seriesID := c.Param("id")
+	log.Printf("CancelSurgerySeries: Request received for series ID %s", seriesID)
+
+	var cancelled []models.SurgerySchedule
+	var freed []models.TimeSlot
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		_, stages, err := lockSeriesStages(tx, seriesID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, stage := range stages {
+			wasScheduled := stage.Status == models.SurgeryStatusScheduled
+			surgery, err := transitionSurgery(tx, strconv.FormatUint(uint64(stage.ID), 10), models.SurgeryStatusCancelled)
+			if err != nil {
+				return fmt.Errorf("stage %d: %w", stage.SeriesStage, err)
+			}
+			if err := settleCancelledSurgery(tx, &surgery, now); err != nil {
+				return fmt.Errorf("stage %d: %w", stage.SeriesStage, err)
+			}
+			if wasScheduled {
+				freed = append(freed, surgery.Slot())
+			}
+			cancelled = append(cancelled, surgery)
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("CancelSurgerySeries: Transaction failed - %v", err)
+		c.JSON(seriesErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var refunded, retained models.Amount
+	for _, surgery := range cancelled {
+		refunded += surgery.CancellationRefund
+		retained += surgery.CancellationFee
+	}
+
+	response := gin.H{
+		"message":             "Surgery series cancelled",
+		"cancelled":           cancelled,
+		"cancellation_fee":    retained,
+		"cancellation_refund": refunded,
+		"currency":            config.Currency(),
+	}
+	if entries := offerFreedSlots("CancelSurgerySeries", freed); len(entries) > 0 {
+		response["waitlist_entries"] = entries
+	}
+
+	log.Printf("CancelSurgerySeries: Cancelled %d stages of series %s, retained %s, refunded %s", len(cancelled), seriesID, retained, refunded)
+	c.JSON(http.StatusOK, response)
+}
+
+func RescheduleSurgerySeries(c *gin.Context) {
+	seriesID := c.Param("id")
+	log.Printf("RescheduleSurgerySeries: Request received for series ID %s", seriesID)
+
+	var request models.SurgerySeriesRescheduleRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("RescheduleSurgerySeries: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var series models.SurgerySeries
+	var freed []models.TimeSlot
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var stages []models.SurgerySchedule
+		var err error
+		series, stages, err = lockSeriesStages(tx, seriesID)
+		if err != nil {
+			return err
+		}
+
+		// Moving later stages first keeps a stage from colliding with the old
+		// slot of the next one when the whole series shifts forward.
+		shift := request.ScheduledAt.Sub(stages[0].ScheduledAt)
+		if shift > 0 {
+			sort.SliceStable(stages, func(i, j int) bool { return stages[i].SeriesStage > stages[j].SeriesStage })
+		}
+
+		for i := range stages {
+			stage := &stages[i]
+			if stage.Status == models.SurgeryStatusScheduled {
+				freed = append(freed, stage.Slot())
+			}
+			scheduledAt := stage.ScheduledAt.Add(shift)
+			if err := rescheduleSurgery(tx, stage, models.SurgeryRescheduleRequest{
+				ScheduledAt: &scheduledAt,
+				Reason:      request.Reason,
+			}); err != nil {
+				log.Printf("RescheduleSurgerySeries: Stage %d does not fit - %v", stage.SeriesStage, err)
+				return fmt.Errorf("stage %d: %w", stage.SeriesStage, err)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("RescheduleSurgerySeries: Transaction failed - %v", err)
+		c.JSON(seriesErrorStatus(err), gin.H{
+			"error":   "Failed to reschedule surgery series, no stage was moved",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Stages", orderedStages).Preload("Stages.OperatingTheater").First(&series, series.ID)
+
+	response := gin.H{
+		"message": "Surgery series rescheduled successfully",
+		"series":  series,
+	}
+	if entries := offerFreedSlots("RescheduleSurgerySeries", freed); len(entries) > 0 {
+		response["waitlist_entries"] = entries
+	}
+
+	log.Printf("RescheduleSurgerySeries: Series %d moved to start at %s", series.ID, request.ScheduledAt.Format(time.RFC3339))
+	c.JSON(http.StatusOK, response)
+}
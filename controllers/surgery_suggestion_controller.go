@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSuggestionCount = 5
+	maxSuggestionCount     = 50
+	defaultSuggestionRange = 7 * 24 * time.Hour
+	maxSuggestionRange     = 31 * 24 * time.Hour
+	// maxSuggestionChecks bounds the theater lookups of one search.
+	maxSuggestionChecks = 500
+)
+
+type suggestionQuery struct {
+	PatientID   uint
+	Doctors     []models.Doctor
+	SurgeryType models.SurgeryType
+	Duration    time.Duration
+	Window      models.TimeSlot
+	Step        time.Duration
+	Limit       int
+}
+
+type slotCandidate struct {
+	Start  time.Time
+	Doctor models.Doctor
+}
+
+// alignToStep rounds t up to the next multiple of step.
+func alignToStep(t time.Time, step time.Duration) time.Time {
+	aligned := t.Truncate(step)
+	if aligned.Before(t) {
+		aligned = aligned.Add(step)
+	}
+	return aligned
+}
+
+// patientBusySlots lists the patient's active surgeries and appointments
+// touching window, the same clashes checkPatientFree rejects at booking.
+func patientBusySlots(tx *gorm.DB, patientID uint, window models.TimeSlot) ([]models.TimeSlot, error) {
+	var surgeries []models.SurgerySchedule
+	if err := overlappingSurgeries(tx, window.Start, window.End).
+		Where("patient_id = ?", patientID).
+		Find(&surgeries).Error; err != nil {
+		return nil, err
+	}
+	var appointments []models.Appointment
+	if err := overlappingAppointments(tx, window.Start, window.End).
+		Where("patient_id = ?", patientID).
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	busy := make([]models.TimeSlot, 0, len(surgeries)+len(appointments))
+	for _, surgery := range surgeries {
+		busy = append(busy, surgery.Slot())
+	}
+	for _, appointment := range appointments {
+		busy = append(busy, models.TimeSlot{Start: appointment.ScheduledAt, End: appointment.ScheduledEnd})
+	}
+	return busy, nil
+}
+
+// suggestSurgerySlots returns the earliest start times at which one of the
+// doctors is rostered and free, the patient has nothing else booked and a
+// theater meeting the surgery type's requirements is free. Theaters are
+// picked the way booking picks them, but nothing is locked.
+func suggestSurgerySlots(tx *gorm.DB, q suggestionQuery) ([]models.SlotSuggestion, error) {
+	patientBusy, err := patientBusySlots(tx, q.PatientID, q.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []slotCandidate{}
+	for _, doctor := range q.Doctors {
+		rostered, err := doctorRosteredSlots(tx, doctor.ID, q.Window)
+		if err != nil {
+			return nil, err
+		}
+		busy, err := doctorBusySlots(tx, doctor.ID, q.Window)
+		if err != nil {
+			return nil, err
+		}
+		busy = append(busy, patientBusy...)
+
+		for _, roster := range rostered {
+			for _, free := range subtractSlots(roster, busy, q.Duration) {
+				for start := alignToStep(free.Start, q.Step); !start.Add(q.Duration).After(free.End); start = start.Add(q.Step) {
+					candidates = append(candidates, slotCandidate{Start: start, Doctor: doctor})
+				}
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Start.Before(candidates[j].Start) })
+
+	constraints := constraintsFromSurgeryType(q.SurgeryType)
+	suggestions := []models.SlotSuggestion{}
+	for i, candidate := range candidates {
+		if len(suggestions) >= q.Limit || i >= maxSuggestionChecks {
+			break
+		}
+		slot := models.TimeSlot{Start: candidate.Start, End: candidate.Start.Add(q.Duration)}
+		ot, units, err := availableOperatingTheater(tx, constraints, slot)
+		if errors.Is(err, errNoOperatingTheater) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		suggestion := models.SlotSuggestion{
+			TimeSlot:             slot,
+			DoctorID:             candidate.Doctor.ID,
+			DoctorName:           candidate.Doctor.Name,
+			OperatingTheaterID:   ot.ID,
+			OperatingTheaterName: ot.Name,
+		}
+		for _, unit := range units {
+			suggestion.MovableEquipment = append(suggestion.MovableEquipment, unit.Name)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+// suggestionDoctors returns the requested doctor, or every doctor with the
+// specialty, keeping only those credentialed for the surgery type.
+func suggestionDoctors(tx *gorm.DB, doctorID, specialty string, surgeryType models.SurgeryType) ([]models.Doctor, error) {
+	query := tx.Order("id")
+	switch {
+	case doctorID != "":
+		query = query.Where("id = ?", doctorID)
+	case specialty != "":
+		query = withSpecialty(query, specialty)
+	default:
+		return nil, errors.New("doctor_id or specialty is required")
+	}
+
+	var doctors []models.Doctor
+	if err := query.Find(&doctors).Error; err != nil {
+		return nil, err
+	}
+	if doctorID != "" && len(doctors) == 0 {
+		return nil, fmt.Errorf("doctor %s not found", doctorID)
+	}
+
+	qualified := []models.Doctor{}
+	for _, doctor := range doctors {
+		err := checkDoctorCredentials(tx, doctor.ID, surgeryType)
+		if err == nil {
+			qualified = append(qualified, doctor)
+			continue
+		}
+		if doctorID != "" {
+			return nil, err
+		}
+	}
+	return qualified, nil
+}
+
+func GetSurgerySuggestions(c *gin.Context) {
+	log.Printf("GetSurgerySuggestions: Request received for patient_id=%s doctor_id=%s specialty=%s surgery_type=%s",
+		c.Query("patient_id"), c.Query("doctor_id"), c.Query("specialty"), c.Query("surgery_type"))
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, "id = ?", c.Query("patient_id")).Error; err != nil {
+		log.Printf("GetSurgerySuggestions: Patient not found with ID %s", c.Query("patient_id"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "patient_id must name an existing patient"})
+		return
+	}
+
+	var surgeryType models.SurgeryType
+	if err := config.DB.Where("code = ?", c.Query("surgery_type")).First(&surgeryType).Error; err != nil {
+		log.Printf("GetSurgerySuggestions: Surgery type %q not in the catalog", c.Query("surgery_type"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("surgery type %q is not in the catalog", c.Query("surgery_type"))})
+		return
+	}
+
+	minutes := surgeryType.DefaultDuration
+	if durationStr := c.Query("duration"); durationStr != "" {
+		parsed, err := strconv.Atoi(durationStr)
+		if err != nil || parsed <= 0 {
+			log.Printf("GetSurgerySuggestions: Invalid duration %s", durationStr)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration. Use a positive number of minutes"})
+			return
+		}
+		minutes = parsed
+	}
+	if minutes <= 0 {
+		log.Printf("GetSurgerySuggestions: No duration for surgery type %s", surgeryType.Code)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("duration is required, surgery type %q has no default duration", surgeryType.Code)})
+		return
+	}
+
+	start, end, err := parseTimeWindow(c.Query("start"), c.Query("end"))
+	if err != nil {
+		log.Printf("GetSurgerySuggestions: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if now := time.Now(); start.Before(now) {
+		start = now
+	}
+	if c.Query("end") == "" {
+		end = start.Add(defaultSuggestionRange)
+	}
+	if end.Sub(start) > maxSuggestionRange {
+		end = start.Add(maxSuggestionRange)
+	}
+	if !end.After(start) {
+		log.Printf("GetSurgerySuggestions: Empty date range")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end must be in the future"})
+		return
+	}
+
+	limit := defaultSuggestionCount
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > maxSuggestionCount {
+			log.Printf("GetSurgerySuggestions: Invalid limit %s", limitStr)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid limit. Use 1 to %d", maxSuggestionCount)})
+			return
+		}
+		limit = parsed
+	}
+
+	step := 15 * time.Minute
+	if stepStr := c.Query("step"); stepStr != "" {
+		parsed, err := strconv.Atoi(stepStr)
+		if err != nil || parsed < 5 {
+			log.Printf("GetSurgerySuggestions: Invalid step %s", stepStr)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step. Use at least 5 minutes"})
+			return
+		}
+		step = time.Duration(parsed) * time.Minute
+	}
+
+	doctors, err := suggestionDoctors(config.DB, c.Query("doctor_id"), c.Query("specialty"), surgeryType)
+	if err != nil {
+		log.Printf("GetSurgerySuggestions: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := suggestionQuery{
+		PatientID:   patient.ID,
+		Doctors:     doctors,
+		SurgeryType: surgeryType,
+		Duration:    time.Duration(minutes) * time.Minute,
+		Window:      models.TimeSlot{Start: start, End: end},
+		Step:        step,
+		Limit:       limit,
+	}
+
+	suggestions, err := suggestSurgerySlots(config.DB, query)
+	if err != nil {
+		log.Printf("GetSurgerySuggestions: Error searching slots - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetSurgerySuggestions: Found %d slots across %d doctors", len(suggestions), len(doctors))
+	c.JSON(http.StatusOK, gin.H{
+		"patient_id":   patient.ID,
+		"surgery_type": surgeryType.Code,
+		"duration":     minutes,
+		"start":        start,
+		"end":          end,
+		"suggestions":  suggestions,
+	})
+}
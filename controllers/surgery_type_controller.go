@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// resolveSurgeryType looks up the catalog entry for the request and fills in
+// the default duration and deposit the caller left out.
+func resolveSurgeryType(tx *gorm.DB, request *models.SurgeryScheduleRequest) (models.SurgeryType, error) {
+	var surgeryType models.SurgeryType
+	if err := tx.Where("code = ?", request.SurgeryType).First(&surgeryType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return surgeryType, fmt.Errorf("surgery type %q is not in the catalog", request.SurgeryType)
+		}
+		return surgeryType, err
+	}
+
+	if request.EstimatedDuration == 0 {
+		request.EstimatedDuration = surgeryType.DefaultDuration
+	}
+	if request.DepositRequired == 0 {
+		request.DepositRequired = surgeryType.DefaultDeposit
+	}
+	if request.EstimatedDuration <= 0 {
+		return surgeryType, fmt.Errorf("estimated_duration is required, surgery type %q has no default duration", surgeryType.Code)
+	}
+	return surgeryType, nil
+}
+
+func validateSurgeryTypeAmounts(surgeryType models.SurgeryType) error {
+	if surgeryType.BasePrice < 0 {
+		return errors.New("base price cannot be negative")
+	}
+	if surgeryType.DefaultDeposit < 0 {
+		return errors.New("default deposit cannot be negative")
+	}
+	return nil
+}
+
+func CreateSurgeryType(c *gin.Context) {
+	log.Println("CreateSurgeryType: Request received")
+
+	var input models.SurgeryType
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateSurgeryType: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateSurgeryTypeAmounts(input); err != nil {
+		log.Printf("CreateSurgeryType: Invalid amounts - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&input).Error; err != nil {
+		log.Printf("CreateSurgeryType: Failed to create surgery type - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("CreateSurgeryType: Surgery type created successfully with ID %d", input.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+func GetAllSurgeryTypes(c *gin.Context) {
+	log.Println("GetAllSurgeryTypes: Request received")
+
+	var surgeryTypes []models.SurgeryType
+
+	if err := config.DB.Find(&surgeryTypes).Error; err != nil {
+		log.Printf("GetAllSurgeryTypes: Error fetching surgery types - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetAllSurgeryTypes: Found %d surgery types", len(surgeryTypes))
+	c.JSON(http.StatusOK, surgeryTypes)
+}
+
+func GetSurgeryTypeByID(c *gin.Context) {
+	log.Printf("GetSurgeryTypeByID: Request received for ID %s", c.Param("id"))
+
+	var surgeryType models.SurgeryType
+
+	if err := config.DB.Where("id = ?", c.Param("id")).First(&surgeryType).Error; err != nil {
+		log.Printf("GetSurgeryTypeByID: Surgery type not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Surgery type not found!"})
+		return
+	}
+
+	log.Printf("GetSurgeryTypeByID: Surgery type found with ID %d", surgeryType.ID)
+	c.JSON(http.StatusOK, surgeryType)
+}
+
+func UpdateSurgeryType(c *gin.Context) {
+	log.Printf("UpdateSurgeryType: Request received for ID %s", c.Param("id"))
+
+	var surgeryType models.SurgeryType
+	id := c.Param("id")
+
+	if err := config.DB.First(&surgeryType, "id = ?", id).Error; err != nil {
+		log.Printf("UpdateSurgeryType: Surgery type not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Surgery type not found!"})
+		return
+	}
+
+	var input struct {
+		Name              *string        `json:"name"`
+		BasePrice         *models.Amount `json:"base_price"`
+		DefaultDuration   *int           `json:"default_duration" binding:"omitempty,gte=0"`
+		DefaultDeposit    *models.Amount `json:"default_deposit"`
+		RequiredSpecialty *string        `json:"required_specialty"`
+		RequiredEquipment *[]string      `json:"required_equipment"`
+		RequiredOTClass   *string        `json:"required_ot_class"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateSurgeryType: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		surgeryType.Name = *input.Name
+	}
+	if input.BasePrice != nil {
+		surgeryType.BasePrice = *input.BasePrice
+	}
+	if input.DefaultDuration != nil {
+		surgeryType.DefaultDuration = *input.DefaultDuration
+	}
+	if input.DefaultDeposit != nil {
+		surgeryType.DefaultDeposit = *input.DefaultDeposit
+	}
+	if input.RequiredSpecialty != nil {
+		surgeryType.RequiredSpecialty = *input.RequiredSpecialty
+	}
+	if input.RequiredEquipment != nil {
+		surgeryType.RequiredEquipment = *input.RequiredEquipment
+	}
+	if input.RequiredOTClass != nil {
+		surgeryType.RequiredOTClass = *input.RequiredOTClass
+	}
+	if err := validateSurgeryTypeAmounts(surgeryType); err != nil {
+		log.Printf("UpdateSurgeryType: Invalid amounts - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	surgeryType.UpdatedAt = time.Now()
+
+	config.DB.Save(&surgeryType)
+	log.Printf("UpdateSurgeryType: Surgery type updated successfully with ID %s", id)
+	c.JSON(http.StatusOK, surgeryType)
+}
+
+func DeleteSurgeryType(c *gin.Context) {
+	log.Printf("DeleteSurgeryType: Request received for ID %s", c.Param("id"))
+
+	var surgeryType models.SurgeryType
+	id := c.Param("id")
+
+	if err := config.DB.First(&surgeryType, "id = ?", id).Error; err != nil {
+		log.Printf("DeleteSurgeryType: Surgery type not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Surgery type not found!"})
+		return
+	}
+
+	config.DB.Delete(&surgeryType)
+	log.Printf("DeleteSurgeryType: Surgery type deleted successfully with ID %s", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Surgery type deleted successfully"})
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errTeamMemberNotFound = errors.New("team member not found")
+
+// validateSurgicalTeam checks roles, rejects duplicates and the lead surgeon,
+// and locks every member to make sure they are free during slot.
+func validateSurgicalTeam(tx *gorm.DB, leadDoctorID uint, team []models.TeamMemberRequest, slot models.TimeSlot, excludeSurgeryID uint) error {
+	seen := map[uint]bool{leadDoctorID: true}
+	for _, member := range team {
+		if !member.Role.IsValid() {
+			return fmt.Errorf("invalid team role %q", member.Role)
+		}
+		if seen[member.DoctorID] {
+			return fmt.Errorf("doctor %d is already part of this surgery", member.DoctorID)
+		}
+		seen[member.DoctorID] = true
+
+		if _, err := lockAvailableDoctor(tx, member.DoctorID, slot, excludeSurgeryID); err != nil {
+			return fmt.Errorf("%s unavailable: %w", member.Role, err)
+		}
+	}
+	return nil
+}
+
+func createSurgicalTeam(tx *gorm.DB, surgeryID uint, team []models.TeamMemberRequest) error {
+	for _, member := range team {
+		row := models.SurgicalTeamMember{
+			SurgeryScheduleID: surgeryID,
+			DoctorID:          member.DoctorID,
+			Role:              member.Role,
+		}
+		if err := tx.Omit(clause.Associations).Create(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func teamRequests(team []models.SurgicalTeamMember) []models.TeamMemberRequest {
+	requests := make([]models.TeamMemberRequest, 0, len(team))
+	for _, member := range team {
+		requests = append(requests, models.TeamMemberRequest{DoctorID: member.DoctorID, Role: member.Role})
+	}
+	return requests
+}
+
+func GetSurgicalTeam(c *gin.Context) {
+	log.Printf("GetSurgicalTeam: Request received for surgery ID %s", c.Param("id"))
+
+	var team []models.SurgicalTeamMember
+
+	if err := config.DB.Preload("Doctor").Where("surgery_schedule_id = ?", c.Param("id")).Find(&team).Error; err != nil {
+		log.Printf("GetSurgicalTeam: Error fetching team - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetSurgicalTeam: Found %d team members for surgery %s", len(team), c.Param("id"))
+	c.JSON(http.StatusOK, team)
+}
+
+func AddSurgicalTeamMember(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("AddSurgicalTeamMember: Request received for surgery ID %s", surgeryID)
+
+	var input models.TeamMemberRequest
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("AddSurgicalTeamMember: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var member models.SurgicalTeamMember
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var surgery models.SurgerySchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", surgeryID).
+			First(&surgery).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errSurgeryNotFound
+			}
+			return err
+		}
+
+		switch surgery.Status {
+		case models.SurgeryStatusScheduled, models.SurgeryStatusInProgress, models.SurgeryStatusPostponed:
+		default:
+			return fmt.Errorf("%w: cannot change the team of a %s surgery", models.ErrInvalidSurgeryTransition, surgery.Status)
+		}
+
+		var existing []models.SurgicalTeamMember
+		if err := tx.Where("surgery_schedule_id = ?", surgery.ID).Find(&existing).Error; err != nil {
+			return err
+		}
+		for _, other := range existing {
+			if other.DoctorID == input.DoctorID {
+				return fmt.Errorf("doctor %d is already part of this surgery", input.DoctorID)
+			}
+		}
+
+		if err := validateSurgicalTeam(tx, surgery.DoctorID, []models.TeamMemberRequest{input}, surgery.Slot(), surgery.ID); err != nil {
+			return err
+		}
+
+		member = models.SurgicalTeamMember{
+			SurgeryScheduleID: surgery.ID,
+			DoctorID:          input.DoctorID,
+			Role:              input.Role,
+		}
+		return tx.Omit(clause.Associations).Create(&member).Error
+	})
+
+	if err != nil {
+		log.Printf("AddSurgicalTeamMember: Transaction failed - %v", err)
+		c.JSON(surgeryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	config.DB.Preload("Doctor").First(&member, member.ID)
+
+	log.Printf("AddSurgicalTeamMember: Doctor %d added as %s to surgery %s", member.DoctorID, member.Role, surgeryID)
+	c.JSON(http.StatusCreated, member)
+}
+
+// RemoveSurgicalTeamMember only works before the surgery starts; once it is
+// under way the team is part of what gets billed and published.
+func RemoveSurgicalTeamMember(c *gin.Context) {
+	surgeryID := c.Param("id")
+	log.Printf("RemoveSurgicalTeamMember: Request received for surgery ID %s, member ID %s", surgeryID, c.Param("member_id"))
+
+	var member models.SurgicalTeamMember
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var surgery models.SurgerySchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", surgeryID).
+			First(&surgery).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errSurgeryNotFound
+			}
+			return err
+		}
+
+		if surgery.Status != models.SurgeryStatusScheduled && surgery.Status != models.SurgeryStatusPostponed {
+			return fmt.Errorf("%w: cannot remove team members from a %s surgery", models.ErrInvalidSurgeryTransition, surgery.Status)
+		}
+
+		if err := tx.First(&member, "id = ? AND surgery_schedule_id = ?", c.Param("member_id"), surgery.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errTeamMemberNotFound
+			}
+			return err
+		}
+		return tx.Delete(&member).Error
+	})
+
+	if err != nil {
+		log.Printf("RemoveSurgicalTeamMember: Transaction failed - %v", err)
+		status := surgeryErrorStatus(err)
+		if errors.Is(err, errTeamMemberNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("RemoveSurgicalTeamMember: Member %d removed from surgery %s", member.ID, surgeryID)
+	c.JSON(http.StatusOK, gin.H{"message": "Team member removed successfully"})
+}
@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"CRUD-hospital-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errNoOperatingTheater means suitable theaters exist but none is free for the
+// requested slot, so the same request may succeed later.
+var errNoOperatingTheater = errors.New("no operating theater available")
+
+type theaterConstraints struct {
+	TheaterID         *uint
+	MinCapacity       int
+	Floor             *int
+	Class             string
+	RequiredEquipment []string
+}
+
+func constraintsFromRequest(request models.SurgeryScheduleRequest, surgeryType models.SurgeryType) theaterConstraints {
+	return theaterConstraints{
+		TheaterID:         request.OperatingTheaterID,
+		MinCapacity:       request.MinCapacity,
+		Floor:             request.Floor,
+		Class:             surgeryType.RequiredOTClass,
+		RequiredEquipment: mergeEquipment(request.RequiredEquipment, surgeryType.RequiredEquipment),
+	}
+}
+
+// constraintsFromSurgeryType covers the catalog requirements of a type, for
+// searches that have no request to go on.
+func constraintsFromSurgeryType(surgeryType models.SurgeryType) theaterConstraints {
+	return theaterConstraints{
+		Class:             surgeryType.RequiredOTClass,
+		RequiredEquipment: mergeEquipment(surgeryType.RequiredEquipment),
+	}
+}
+
+// constraintsForSurgery rebuilds the constraints a booked surgery was placed
+// with, for when it has to move to another theater. Surgery types that are
+// not in the catalog only keep what is stored on the surgery.
+func constraintsForSurgery(tx *gorm.DB, surgery models.SurgerySchedule) (theaterConstraints, error) {
+	var surgeryType models.SurgeryType
+	if err := tx.Where("code = ?", surgery.SurgeryType).First(&surgeryType).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return theaterConstraints{}, err
+	}
+
+	constraints := constraintsFromSurgeryType(surgeryType)
+	constraints.RequiredEquipment = mergeEquipment(constraints.RequiredEquipment, surgery.RequiredEquipment)
+	constraints.MinCapacity = surgery.MinCapacity
+	constraints.Floor = surgery.RequiredFloor
+	return constraints, nil
+}
+
+func mergeEquipment(lists ...[]string) []string {
+	merged := []string{}
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, name := range list {
+			key := strings.ToLower(strings.TrimSpace(name))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, strings.TrimSpace(name))
+		}
+	}
+	return merged
+}
+
+func (c theaterConstraints) String() string {
+	parts := []string{}
+	if c.TheaterID != nil {
+		parts = append(parts, fmt.Sprintf("operating_theater_id=%d", *c.TheaterID))
+	}
+	if c.MinCapacity > 0 {
+		parts = append(parts, fmt.Sprintf("min_capacity=%d", c.MinCapacity))
+	}
+	if c.Floor != nil {
+		parts = append(parts, fmt.Sprintf("floor=%d", *c.Floor))
+	}
+	if c.Class != "" {
+		parts = append(parts, "class="+c.Class)
+	}
+	if len(c.RequiredEquipment) > 0 {
+		parts = append(parts, "required_equipment="+strings.Join(c.RequiredEquipment, ","))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// theaterMismatch explains why ot cannot satisfy the constraints, or returns
+// an empty string when it can.
+func theaterMismatch(ot models.OperatingTheater, c theaterConstraints) string {
+	if ot.Status == models.OTStatusMaintenance {
+		return "it is under maintenance"
+	}
+	if ot.Capacity < c.MinCapacity {
+		return fmt.Sprintf("capacity %d is below the required %d", ot.Capacity, c.MinCapacity)
+	}
+	if c.Floor != nil && ot.Floor != *c.Floor {
+		return fmt.Sprintf("it is on floor %d, not floor %d", ot.Floor, *c.Floor)
+	}
+	if c.Class != "" && !strings.EqualFold(ot.Class, c.Class) {
+		return fmt.Sprintf("it is a %q theater, not %q", ot.Class, c.Class)
+	}
+	return ""
+}
+
+// missingEquipment lists the required equipment that is not installed in ot.
+// Movable units homed in the theater do not count, they have to be booked.
+func missingEquipment(ot models.OperatingTheater, required []string) []string {
+	missing := []string{}
+	for _, name := range required {
+		found := false
+		for _, item := range ot.Equipment {
+			if !item.Movable && strings.EqualFold(strings.TrimSpace(item.Name), strings.TrimSpace(name)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// lockRows adds FOR UPDATE to a query when lock is set. Bookings lock the rows
+// they pick from, read-only searches leave them alone.
+func lockRows(tx *gorm.DB, lock bool) *gorm.DB {
+	if lock {
+		return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	return tx
+}
+
+// reserveMovableEquipment locks the movable units of each named kind and
+// picks one that is not booked by another active surgery during slot. The
+// error names the first kind with no free unit.
+func reserveMovableEquipment(tx *gorm.DB, names []string, slot models.TimeSlot, excludeSurgeryID uint) ([]models.Equipment, error) {
+	return findMovableEquipment(tx, names, slot, excludeSurgeryID, true)
+}
+
+func findMovableEquipment(tx *gorm.DB, names []string, slot models.TimeSlot, excludeSurgeryID uint, lock bool) ([]models.Equipment, error) {
+	reserved := []models.Equipment{}
+	for _, name := range names {
+		var units []models.Equipment
+		if err := lockRows(tx, lock).
+			Where("movable = ? AND LOWER(name) = LOWER(?)", true, strings.TrimSpace(name)).
+			Order("id").
+			Find(&units).Error; err != nil {
+			return nil, err
+		}
+		if len(units) == 0 {
+			return nil, fmt.Errorf("no movable %s in the inventory", name)
+		}
+
+		found := false
+		for _, unit := range units {
+			booked, err := isEquipmentBooked(tx, unit.ID, slot, excludeSurgeryID)
+			if err != nil {
+				return nil, err
+			}
+			if !booked {
+				reserved = append(reserved, unit)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("all %d movable %s units are booked", len(units), name)
+		}
+	}
+	return reserved, nil
+}
+
+func isEquipmentBooked(tx *gorm.DB, equipmentID uint, slot models.TimeSlot, excludeSurgeryID uint) (bool, error) {
+	active := tx.Session(&gorm.Session{NewDB: true}).Model(&models.SurgerySchedule{}).
+		Select("id").
+		Where("status NOT IN ?", models.InactiveSurgeryStatuses)
+	if excludeSurgeryID != 0 {
+		active = active.Where("id <> ?", excludeSurgeryID)
+	}
+
+	var count int64
+	err := tx.Model(&models.EquipmentBooking{}).
+		Where("equipment_id = ? AND start_at < ? AND end_at > ? AND surgery_schedule_id IN (?)", equipmentID, slot.End, slot.Start, active).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// bookEquipment replaces the surgery's equipment bookings with units for its
+// current slot.
+func bookEquipment(tx *gorm.DB, surgery models.SurgerySchedule, units []models.Equipment) error {
+	if err := tx.Where("surgery_schedule_id = ?", surgery.ID).Delete(&models.EquipmentBooking{}).Error; err != nil {
+		return err
+	}
+	slot := surgery.Slot()
+	for _, unit := range units {
+		booking := models.EquipmentBooking{
+			EquipmentID:       unit.ID,
+			SurgeryScheduleID: surgery.ID,
+			StartAt:           slot.Start,
+			EndAt:             slot.End,
+		}
+		if err := tx.Omit(clause.Associations).Create(&booking).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// candidateTheaters locks the theaters satisfying the constraints, ordered so
+// that theaters with the equipment installed and the smallest capacity come
+// first, keeping movable units and large rooms free for those that need them.
+func candidateTheaters(tx *gorm.DB, c theaterConstraints) ([]models.OperatingTheater, error) {
+	return findCandidateTheaters(tx, c, true)
+}
+
+func findCandidateTheaters(tx *gorm.DB, c theaterConstraints, lock bool) ([]models.OperatingTheater, error) {
+	query := lockRows(tx, lock).Preload("Equipment").Order("id")
+	if c.TheaterID != nil {
+		query = query.Where("id = ?", *c.TheaterID)
+	}
+
+	var theaters []models.OperatingTheater
+	if err := query.Find(&theaters).Error; err != nil {
+		return nil, err
+	}
+	if c.TheaterID != nil && len(theaters) == 0 {
+		return nil, fmt.Errorf("operating theater %d not found", *c.TheaterID)
+	}
+
+	matching := []models.OperatingTheater{}
+	for _, ot := range theaters {
+		if reason := theaterMismatch(ot, c); reason != "" {
+			if c.TheaterID != nil {
+				return nil, fmt.Errorf("operating theater %d cannot be used: %s", ot.ID, reason)
+			}
+			continue
+		}
+		matching = append(matching, ot)
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("no operating theater satisfies the requested constraints (%s)", c)
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		mi, mj := len(missingEquipment(matching[i], c.RequiredEquipment)), len(missingEquipment(matching[j], c.RequiredEquipment))
+		if mi != mj {
+			return mi < mj
+		}
+		return matching[i].Capacity < matching[j].Capacity
+	})
+	return matching, nil
+}
+
+// selectOperatingTheater returns the pinned theater, or the first candidate
+// that is free during slot, together with the movable units to book for
+// equipment the theater does not have installed. The error explains why
+// nothing fits.
+func selectOperatingTheater(tx *gorm.DB, c theaterConstraints, slot models.TimeSlot, excludeSurgeryID uint) (models.OperatingTheater, []models.Equipment, error) {
+	return findOperatingTheater(tx, c, slot, excludeSurgeryID, true)
+}
+
+// availableOperatingTheater makes the same choice as selectOperatingTheater
+// without locking anything, for searches that only report what is free.
+func availableOperatingTheater(tx *gorm.DB, c theaterConstraints, slot models.TimeSlot) (models.OperatingTheater, []models.Equipment, error) {
+	return findOperatingTheater(tx, c, slot, 0, false)
+}
+
+func findOperatingTheater(tx *gorm.DB, c theaterConstraints, slot models.TimeSlot, excludeSurgeryID uint, lock bool) (models.OperatingTheater, []models.Equipment, error) {
+	matching, err := findCandidateTheaters(tx, c, lock)
+	if err != nil {
+		return models.OperatingTheater{}, nil, err
+	}
+
+	var lastReason string
+	for _, ot := range matching {
+		conflict, err := findTheaterConflict(tx, ot, slot, excludeSurgeryID)
+		if err != nil {
+			return models.OperatingTheater{}, nil, err
+		}
+		if conflict == "" {
+			units, err := findMovableEquipment(tx, missingEquipment(ot, c.RequiredEquipment), slot, excludeSurgeryID, lock)
+			if err == nil {
+				return ot, units, nil
+			}
+			conflict = "it is missing equipment: " + err.Error()
+		}
+		if c.TheaterID != nil {
+			return models.OperatingTheater{}, nil, fmt.Errorf("%w: operating theater %d is not usable between %s and %s: %s",
+				errNoOperatingTheater, *c.TheaterID, slot.Start.Format(time.RFC3339), slot.End.Format(time.RFC3339), conflict)
+		}
+		lastReason = conflict
+	}
+
+	return models.OperatingTheater{}, nil, fmt.Errorf("%w: all %d operating theaters matching the constraints (%s) are booked or blocked between %s and %s (last: %s)",
+		errNoOperatingTheater, len(matching), c, slot.Start.Format(time.RFC3339), slot.End.Format(time.RFC3339), lastReason)
+}
@@ -0,0 +1,357 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// waitlistCandidates caps how many entries are tried for one freed slot.
+const waitlistCandidates = 20
+
+// addToWaitlist queues a prepared request. Without a preferred range the
+// request accepts any start from the requested time up to the waitlist window.
+func addToWaitlist(tx *gorm.DB, request models.SurgeryScheduleRequest, reason string) (models.WaitlistEntry, error) {
+	earliest := request.ScheduledAt
+	if request.PreferredFrom != nil {
+		earliest = *request.PreferredFrom
+	}
+	latest := earliest.Add(config.WaitlistWindow())
+	if request.PreferredUntil != nil {
+		latest = *request.PreferredUntil
+	}
+	if !latest.After(earliest) {
+		return models.WaitlistEntry{}, errors.New("preferred_until must be after preferred_from")
+	}
+
+	entry := models.WaitlistEntry{
+		PatientID:   request.PatientID,
+		DoctorID:    request.DoctorID,
+		SurgeryType: request.SurgeryType,
+		Priority:    request.Priority,
+		EarliestAt:  earliest,
+		LatestAt:    latest,
+		AutoBook:    request.AutoBook,
+		Status:      models.WaitlistWaiting,
+		Reason:      reason,
+		Request:     request,
+	}
+	err := tx.Create(&entry).Error
+	return entry, err
+}
+
+// bookWaitlistEntry books the entry's request at start and marks the entry
+// as booked. It only takes a slot that is actually free and never bumps other
+// surgeries; nothing is written when booking fails.
+func bookWaitlistEntry(entry *models.WaitlistEntry, start time.Time) (models.SurgerySchedule, error) {
+	var surgery models.SurgerySchedule
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(entry, entry.ID).Error; err != nil {
+			return err
+		}
+		if entry.Status != models.WaitlistWaiting && entry.Status != models.WaitlistOffered {
+			return fmt.Errorf("waitlist entry %d is %s", entry.ID, entry.Status)
+		}
+
+		request := entry.Request
+		request.ScheduledAt = start
+		surgeryType, err := prepareSurgeryRequest(tx, &request)
+		if err != nil {
+			return err
+		}
+		surgery, _, err = bookSurgery(tx, request, surgeryType, false)
+		if err != nil {
+			return err
+		}
+
+		entry.Status = models.WaitlistBooked
+		entry.SurgeryScheduleID = &surgery.ID
+		if err := tx.Save(entry).Error; err != nil {
+			return err
+		}
+		return notifySurgeryDoctors(tx, surgery, fmt.Sprintf("Surgery %d for patient %d was booked at %s from waitlist entry %d.",
+			surgery.ID, surgery.PatientID, surgery.ScheduledAt.Format(time.RFC3339), entry.ID))
+	})
+	return surgery, err
+}
+
+// canBookWaitlistEntry checks whether the entry could be booked at start by
+// running the booking and rolling it back.
+func canBookWaitlistEntry(entry models.WaitlistEntry, start time.Time) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		request := entry.Request
+		request.ScheduledAt = start
+		surgeryType, err := prepareSurgeryRequest(tx, &request)
+		if err != nil {
+			return err
+		}
+		if _, _, err := bookSurgery(tx, request, surgeryType, false); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// expireWaitlistOffers puts entries whose offer ran out, or whose offered
+// slot has already started, back on the waitlist.
+func expireWaitlistOffers(tx *gorm.DB) error {
+	now := time.Now()
+	result := tx.Model(&models.WaitlistEntry{}).
+		Where("status = ? AND (offer_expires_at <= ? OR offered_at <= ?)", models.WaitlistOffered, now, now).
+		Updates(map[string]interface{}{"status": models.WaitlistWaiting, "offered_at": nil, "offer_expires_at": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("expireWaitlistOffers: %d expired offers back on the waitlist", result.RowsAffected)
+	}
+	return nil
+}
+
+// offerFreedSlot gives a slot released by a cancellation or reschedule to the
+// best waitlisted request that fits: the highest priority, then the oldest.
+// Entries with AutoBook are booked straight away, the others are offered to
+// their doctor until the offer times out. It returns the entry that got the
+// slot, if any.
+func offerFreedSlot(freed models.TimeSlot) (*models.WaitlistEntry, error) {
+	now := time.Now()
+	if freed.Start.Before(now) {
+		freed.Start = now
+	}
+	if !freed.End.After(freed.Start) {
+		return nil, nil
+	}
+
+	if err := expireWaitlistOffers(config.DB); err != nil {
+		return nil, err
+	}
+
+	var entries []models.WaitlistEntry
+	if err := config.DB.Where("status = ? AND earliest_at < ? AND latest_at > ?", models.WaitlistWaiting, freed.End, freed.Start).
+		Order(models.WaitlistPriorityOrderSQL).
+		Limit(waitlistCandidates).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		start := freed.Start
+		if entry.EarliestAt.After(start) {
+			start = entry.EarliestAt
+		}
+
+		if entry.AutoBook {
+			surgery, err := bookWaitlistEntry(entry, start)
+			if err != nil {
+				log.Printf("offerFreedSlot: Waitlist entry %d does not fit at %s - %v", entry.ID, start.Format(time.RFC3339), err)
+				continue
+			}
+			log.Printf("offerFreedSlot: Waitlist entry %d booked as surgery %d", entry.ID, surgery.ID)
+			return entry, nil
+		}
+
+		if err := canBookWaitlistEntry(*entry, start); err != nil {
+			log.Printf("offerFreedSlot: Waitlist entry %d does not fit at %s - %v", entry.ID, start.Format(time.RFC3339), err)
+			continue
+		}
+
+		expiresAt := now.Add(config.WaitlistOfferTimeout())
+		if start.Before(expiresAt) {
+			expiresAt = start
+		}
+		entry.Status = models.WaitlistOffered
+		entry.OfferedAt = &start
+		entry.OfferExpiresAt = &expiresAt
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(entry).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.Notification{
+				DoctorID: entry.DoctorID,
+				Message: fmt.Sprintf("A slot at %s is available for waitlisted %s surgery of patient %d (waitlist entry %d). Accept or decline the offer by %s.",
+					start.Format(time.RFC3339), entry.SurgeryType, entry.PatientID, entry.ID, expiresAt.Format(time.RFC3339)),
+			}).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("offerFreedSlot: Slot at %s offered to waitlist entry %d", start.Format(time.RFC3339), entry.ID)
+		return entry, nil
+	}
+	return nil, nil
+}
+
+func AddWaitlistEntry(c *gin.Context) {
+	log.Println("AddWaitlistEntry: Request received")
+
+	var request models.SurgeryScheduleRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("AddWaitlistEntry: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := prepareSurgeryRequest(config.DB, &request); err != nil {
+		log.Printf("AddWaitlistEntry: Invalid request - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := addToWaitlist(config.DB, request, "Added to the waitlist directly")
+	if err != nil {
+		log.Printf("AddWaitlistEntry: Failed to queue request - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("AddWaitlistEntry: Waitlist entry %d created", entry.ID)
+	c.JSON(http.StatusCreated, entry)
+}
+
+func GetWaitlist(c *gin.Context) {
+	log.Println("GetWaitlist: Request received")
+
+	query := config.DB.Order(models.WaitlistPriorityOrderSQL)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	} else {
+		query = query.Where("status IN ?", []models.WaitlistStatus{models.WaitlistWaiting, models.WaitlistOffered})
+	}
+	if doctorID := c.Query("doctor_id"); doctorID != "" {
+		query = query.Where("doctor_id = ?", doctorID)
+	}
+
+	var entries []models.WaitlistEntry
+
+	if err := query.Find(&entries).Error; err != nil {
+		log.Printf("GetWaitlist: Error fetching waitlist - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetWaitlist: Found %d entries", len(entries))
+	c.JSON(http.StatusOK, entries)
+}
+
+func GetWaitlistEntryByID(c *gin.Context) {
+	log.Printf("GetWaitlistEntryByID: Request received for ID %s", c.Param("id"))
+
+	var entry models.WaitlistEntry
+
+	if err := config.DB.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GetWaitlistEntryByID: Waitlist entry not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found!"})
+		return
+	}
+
+	log.Printf("GetWaitlistEntryByID: Waitlist entry found with ID %d", entry.ID)
+	c.JSON(http.StatusOK, entry)
+}
+
+func AcceptWaitlistOffer(c *gin.Context) {
+	log.Printf("AcceptWaitlistOffer: Request received for ID %s", c.Param("id"))
+
+	var entry models.WaitlistEntry
+
+	if err := config.DB.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("AcceptWaitlistOffer: Waitlist entry not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found!"})
+		return
+	}
+	if entry.Status != models.WaitlistOffered || entry.OfferedAt == nil {
+		log.Printf("AcceptWaitlistOffer: Entry %d has no open offer (status %s)", entry.ID, entry.Status)
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("waitlist entry %d has no open offer", entry.ID)})
+		return
+	}
+
+	if entry.OfferExpiresAt != nil && !time.Now().Before(*entry.OfferExpiresAt) {
+		log.Printf("AcceptWaitlistOffer: Offer for entry %d expired at %s", entry.ID, entry.OfferExpiresAt.Format(time.RFC3339))
+		config.DB.Model(&entry).Updates(map[string]interface{}{"status": models.WaitlistWaiting, "offered_at": nil, "offer_expires_at": nil})
+		c.JSON(http.StatusConflict, gin.H{"error": "The offer has expired, the request is back on the waitlist"})
+		return
+	}
+
+	surgery, err := bookWaitlistEntry(&entry, *entry.OfferedAt)
+	if err != nil {
+		log.Printf("AcceptWaitlistOffer: Offered slot no longer available - %v", err)
+		config.DB.Model(&entry).Updates(map[string]interface{}{"status": models.WaitlistWaiting, "offered_at": nil, "offer_expires_at": nil})
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "The offered slot is no longer available, the request is back on the waitlist",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").Preload("OperatingTheater").Preload("Team.Doctor").Preload("EquipmentBookings.Equipment").First(&surgery, surgery.ID)
+
+	log.Printf("AcceptWaitlistOffer: Waitlist entry %d booked as surgery %d", entry.ID, surgery.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Surgery scheduled successfully",
+		"surgery":        surgery,
+		"waitlist_entry": entry,
+	})
+}
+
+func DeclineWaitlistOffer(c *gin.Context) {
+	log.Printf("DeclineWaitlistOffer: Request received for ID %s", c.Param("id"))
+
+	var entry models.WaitlistEntry
+
+	if err := config.DB.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("DeclineWaitlistOffer: Waitlist entry not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found!"})
+		return
+	}
+	if entry.Status != models.WaitlistOffered {
+		log.Printf("DeclineWaitlistOffer: Entry %d has no open offer (status %s)", entry.ID, entry.Status)
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("waitlist entry %d has no open offer", entry.ID)})
+		return
+	}
+
+	entry.Status = models.WaitlistWaiting
+	entry.OfferedAt = nil
+	entry.OfferExpiresAt = nil
+	config.DB.Save(&entry)
+
+	log.Printf("DeclineWaitlistOffer: Offer declined, entry %d is waiting again", entry.ID)
+	c.JSON(http.StatusOK, entry)
+}
+
+func CancelWaitlistEntry(c *gin.Context) {
+	log.Printf("CancelWaitlistEntry: Request received for ID %s", c.Param("id"))
+
+	var entry models.WaitlistEntry
+
+	if err := config.DB.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("CancelWaitlistEntry: Waitlist entry not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found!"})
+		return
+	}
+	if entry.Status == models.WaitlistBooked {
+		log.Printf("CancelWaitlistEntry: Entry %d is already booked", entry.ID)
+		c.JSON(http.StatusConflict, gin.H{"error": "Waitlist entry is already booked, cancel the surgery instead"})
+		return
+	}
+
+	entry.Status = models.WaitlistCancelled
+	config.DB.Save(&entry)
+
+	log.Printf("CancelWaitlistEntry: Waitlist entry %d cancelled", entry.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Waitlist entry cancelled successfully"})
+}
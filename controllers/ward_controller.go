@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"CRUD-hospital-go/config"
+	"CRUD-hospital-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errBedInUse = errors.New("bed is in use")
+
+func orderedBeds(db *gorm.DB) *gorm.DB {
+	return db.Order("label").Order("id")
+}
+
+// wardOccupancy reports the state of every bed of a ward, with its beds
+// loaded, at the current time.
+func wardOccupancy(db *gorm.DB, ward models.Ward) (models.WardOccupancy, error) {
+	occupancy := models.WardOccupancy{
+		WardID:    ward.ID,
+		Name:      ward.Name,
+		Type:      ward.Type,
+		TotalBeds: len(ward.Beds),
+		Beds:      []models.BedOccupancy{},
+	}
+	if len(ward.Beds) == 0 {
+		return occupancy, nil
+	}
+
+	bedIDs := make([]uint, 0, len(ward.Beds))
+	for _, bed := range ward.Beds {
+		bedIDs = append(bedIDs, bed.ID)
+	}
+
+	var admissions []models.Admission
+	if err := db.Where("bed_id IN ? AND status = ?", bedIDs, models.AdmissionStatusAdmitted).Find(&admissions).Error; err != nil {
+		return occupancy, err
+	}
+	admitted := map[uint]models.Admission{}
+	for _, admission := range admissions {
+		admitted[admission.BedID] = admission
+	}
+
+	now := time.Now()
+	var reservations []models.BedReservation
+	if err := activeBedReservations(db).
+		Where("bed_id IN ? AND end_at > ?", bedIDs, now).
+		Order("start_at").
+		Find(&reservations).Error; err != nil {
+		return occupancy, err
+	}
+	nextReservation := map[uint]models.BedReservation{}
+	for _, reservation := range reservations {
+		if _, ok := nextReservation[reservation.BedID]; !ok {
+			nextReservation[reservation.BedID] = reservation
+		}
+	}
+
+	for _, bed := range ward.Beds {
+		entry := models.BedOccupancy{BedID: bed.ID, Label: bed.Label, State: models.BedStateAvailable}
+		if reservation, ok := nextReservation[bed.ID]; ok {
+			entry.NextReservation = &reservation
+		}
+
+		if admission, ok := admitted[bed.ID]; ok {
+			entry.State = models.BedStateOccupied
+			entry.PatientID = &admission.PatientID
+			entry.AdmissionID = &admission.ID
+			entry.ExpectedDischargeAt = admission.ExpectedDischargeAt
+		} else if bed.Status == models.BedStatusMaintenance {
+			entry.State = models.BedStateMaintenance
+		} else if entry.NextReservation != nil && !entry.NextReservation.StartAt.After(now) {
+			entry.State = models.BedStateReserved
+		}
+
+		switch entry.State {
+		case models.BedStateOccupied:
+			occupancy.Occupied++
+		case models.BedStateReserved:
+			occupancy.Reserved++
+		case models.BedStateAvailable:
+			occupancy.Available++
+		}
+		if bed.Status != models.BedStatusMaintenance {
+			occupancy.InService++
+		}
+		occupancy.Beds = append(occupancy.Beds, entry)
+	}
+
+	if occupancy.InService > 0 {
+		occupancy.OccupancyRate = float64(occupancy.Occupied) / float64(occupancy.InService)
+	}
+	return occupancy, nil
+}
+
+func CreateWard(c *gin.Context) {
+	log.Println("CreateWard: Request received")
+
+	var input models.Ward
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("CreateWard: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range input.Beds {
+		if input.Beds[i].Status == "" {
+			input.Beds[i].Status = models.BedStatusAvailable
+		}
+		if !input.Beds[i].Status.IsValid() {
+			log.Printf("CreateWard: Invalid bed status %q", input.Beds[i].Status)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid bed status %q", input.Beds[i].Status)})
+			return
+		}
+	}
+
+	config.DB.Create(&input)
+	log.Printf("CreateWard: Ward created successfully with ID %d and %d beds", input.ID, len(input.Beds))
+	c.JSON(http.StatusCreated, input)
+}
+
+func GetAllWards(c *gin.Context) {
+	log.Println("GetAllWards: Request received")
+
+	var wards []models.Ward
+
+	if err := config.DB.Preload("Beds", orderedBeds).Order("id").Find(&wards).Error; err != nil {
+		log.Printf("GetAllWards: Error fetching wards - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetAllWards: Found %d wards", len(wards))
+	c.JSON(http.StatusOK, wards)
+}
+
+func GetWardByID(c *gin.Context) {
+	log.Printf("GetWardByID: Request received for ID %s", c.Param("id"))
+
+	var ward models.Ward
+
+	if err := config.DB.Preload("Beds", orderedBeds).First(&ward, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GetWardByID: Ward not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ward not found!"})
+		return
+	}
+
+	log.Printf("GetWardByID: Ward found with ID %d", ward.ID)
+	c.JSON(http.StatusOK, ward)
+}
+
+func UpdateWard(c *gin.Context) {
+	log.Printf("UpdateWard: Request received for ID %s", c.Param("id"))
+
+	var ward models.Ward
+	id := c.Param("id")
+
+	if err := config.DB.First(&ward, "id = ?", id).Error; err != nil {
+		log.Printf("UpdateWard: Ward not found with ID %s", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ward not found!"})
+		return
+	}
+
+	var input struct {
+		Name  *string `json:"name"`
+		Floor *int    `json:"floor"`
+		Type  *string `json:"type"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateWard: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		ward.Name = *input.Name
+	}
+	if input.Floor != nil {
+		ward.Floor = *input.Floor
+	}
+	if input.Type != nil {
+		ward.Type = *input.Type
+	}
+	ward.UpdatedAt = time.Now()
+
+	config.DB.Save(&ward)
+	log.Printf("UpdateWard: Ward updated successfully with ID %s", id)
+	c.JSON(http.StatusOK, ward)
+}
+
+func AddWardBed(c *gin.Context) {
+	log.Printf("AddWardBed: Request received for ward ID %s", c.Param("id"))
+
+	var ward models.Ward
+	if err := config.DB.First(&ward, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("AddWardBed: Ward not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ward not found!"})
+		return
+	}
+
+	var input models.Bed
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("AddWardBed: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Status == "" {
+		input.Status = models.BedStatusAvailable
+	}
+	if !input.Status.IsValid() {
+		log.Printf("AddWardBed: Invalid status %q", input.Status)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid status %q", input.Status)})
+		return
+	}
+	input.WardID = ward.ID
+
+	config.DB.Create(&input)
+	log.Printf("AddWardBed: Bed %d added to ward %d", input.ID, ward.ID)
+	c.JSON(http.StatusCreated, input)
+}
+
+// UpdateWardBed relabels a bed or takes it in or out of service. A bed with a
+// patient in it or a post-op reservation ahead cannot go into maintenance.
+func UpdateWardBed(c *gin.Context) {
+	log.Printf("UpdateWardBed: Request received for ward ID %s, bed ID %s", c.Param("id"), c.Param("bed_id"))
+
+	var input struct {
+		Label  *string           `json:"label"`
+		Status *models.BedStatus `json:"status"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("UpdateWardBed: Invalid request body - %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status != nil && !input.Status.IsValid() {
+		log.Printf("UpdateWardBed: Invalid status %q", *input.Status)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid status %q", *input.Status)})
+		return
+	}
+
+	var bed models.Bed
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&bed, "id = ? AND ward_id = ?", c.Param("bed_id"), c.Param("id")).Error; err != nil {
+			return err
+		}
+
+		if input.Status != nil && *input.Status == models.BedStatusMaintenance && bed.Status != models.BedStatusMaintenance {
+			reason, err := findBedConflict(tx, bed, bedHold{Start: time.Now()})
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				return fmt.Errorf("%w: bed %d cannot go into maintenance, %s", errBedInUse, bed.ID, reason)
+			}
+		}
+
+		if input.Label != nil {
+			bed.Label = *input.Label
+		}
+		if input.Status != nil {
+			bed.Status = *input.Status
+		}
+		return tx.Save(&bed).Error
+	})
+
+	if err != nil {
+		log.Printf("UpdateWardBed: Transaction failed - %v", err)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bed not found!"})
+		case errors.Is(err, errBedInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	log.Printf("UpdateWardBed: Bed %d updated, status %s", bed.ID, bed.Status)
+	c.JSON(http.StatusOK, bed)
+}
+
+func GetWardOccupancy(c *gin.Context) {
+	log.Printf("GetWardOccupancy: Request received for ward ID %s", c.Param("id"))
+
+	var ward models.Ward
+
+	if err := config.DB.Preload("Beds", orderedBeds).First(&ward, "id = ?", c.Param("id")).Error; err != nil {
+		log.Printf("GetWardOccupancy: Ward not found with ID %s", c.Param("id"))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ward not found!"})
+		return
+	}
+
+	occupancy, err := wardOccupancy(config.DB, ward)
+	if err != nil {
+		log.Printf("GetWardOccupancy: Error computing occupancy - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("GetWardOccupancy: Ward %d has %d of %d beds occupied", ward.ID, occupancy.Occupied, occupancy.InService)
+	c.JSON(http.StatusOK, occupancy)
+}
+
+func GetAllWardOccupancy(c *gin.Context) {
+	log.Println("GetAllWardOccupancy: Request received")
+
+	var wards []models.Ward
+
+	if err := config.DB.Preload("Beds", orderedBeds).Order("id").Find(&wards).Error; err != nil {
+		log.Printf("GetAllWardOccupancy: Error fetching wards - %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	occupancies := make([]models.WardOccupancy, 0, len(wards))
+	for _, ward := range wards {
+		occupancy, err := wardOccupancy(config.DB, ward)
+		if err != nil {
+			log.Printf("GetAllWardOccupancy: Error computing occupancy of ward %d - %v", ward.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		occupancies = append(occupancies, occupancy)
+	}
+
+	log.Printf("GetAllWardOccupancy: Reported occupancy for %d wards", len(occupancies))
+	c.JSON(http.StatusOK, occupancies)
+}
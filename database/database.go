@@ -2,6 +2,10 @@ package database
 
 import (
 	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
 
 	config "CRUD-hospital-go/config"
 	models "CRUD-hospital-go/models"
@@ -10,12 +14,196 @@ import (
 func InitializeDatabase() {
 	log.Println("InitializeDatabase: Connecting to database...")
 	config.ConnectDatabase()
+	convertMoneyColumnsToMinorUnits()
 	log.Println("InitializeDatabase: Running auto migrations...")
-	config.DB.AutoMigrate(
+	if err := config.DB.AutoMigrate(
 		&models.Doctor{},
+		&models.Specialty{},
+		&models.DoctorPrivilege{},
+		&models.DoctorWorkingHours{},
+		&models.DoctorShift{},
+		&models.DoctorLeave{},
 		&models.Patient{},
 		&models.OperatingTheater{},
+		&models.OTMaintenanceWindow{},
+		&models.Equipment{},
+		&models.EquipmentBooking{},
+		&models.Appointment{},
+		&models.SurgerySeries{},
 		&models.SurgerySchedule{},
-	)
+		&models.SurgeryReschedule{},
+		&models.Notification{},
+		&models.WaitlistEntry{},
+		&models.SurgicalTeamMember{},
+		&models.DepositTransaction{},
+		&models.SurgeryType{},
+		&models.SurgeryConsumable{},
+		&models.Invoice{},
+		&models.InvoiceLine{},
+		&models.Payment{},
+		&models.CancellationPolicyRule{},
+		&models.ChecklistTemplateItem{},
+		&models.SurgeryChecklistItem{},
+		&models.OperativeReport{},
+		&models.Ward{},
+		&models.Bed{},
+		&models.Admission{},
+		&models.BedTransfer{},
+		&models.BedReservation{},
+	); err != nil {
+		log.Fatalf("InitializeDatabase: Auto migration failed - %v", err)
+	}
+	backfillSurgeryEndTimes()
+	backfillDepositLedger()
+	backfillCurrencies()
 	log.Println("InitializeDatabase: Database initialization complete")
 }
+
+func backfillSurgeryEndTimes() {
+	result := config.DB.Exec("UPDATE surgery_schedules SET scheduled_end = DATE_ADD(scheduled_at, INTERVAL estimated_duration MINUTE) WHERE scheduled_end IS NULL")
+	if result.Error != nil {
+		log.Printf("InitializeDatabase: Failed to backfill surgery end times - %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("InitializeDatabase: Backfilled end times for %d surgeries", result.RowsAffected)
+	}
+}
+
+// backfillDepositLedger opens a ledger for patients whose deposit predates it:
+// an opening adjustment for everything they paid in, followed by holds for
+// surgeries that still have money deducted.
+func backfillDepositLedger() {
+	var patients []models.Patient
+	if err := config.DB.Where("NOT EXISTS (SELECT 1 FROM deposit_transactions WHERE deposit_transactions.patient_id = patients.id)").
+		Find(&patients).Error; err != nil {
+		log.Printf("InitializeDatabase: Failed to load patients for ledger backfill - %v", err)
+		return
+	}
+
+	for _, patient := range patients {
+		var surgeries []models.SurgerySchedule
+		config.DB.Where("patient_id = ? AND deposit_deducted > 0 AND status IN ?", patient.ID, []models.SurgeryStatus{
+			models.SurgeryStatusScheduled, models.SurgeryStatusInProgress, models.SurgeryStatusPostponed,
+		}).Find(&surgeries)
+
+		balance := patient.Deposit
+		for _, surgery := range surgeries {
+			balance += surgery.DepositDeducted
+		}
+		if balance == 0 {
+			continue
+		}
+
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			opening := models.DepositTransaction{
+				PatientID:    patient.ID,
+				Type:         models.DepositAdjustment,
+				Amount:       balance,
+				BalanceAfter: balance,
+				Note:         "Opening balance",
+			}
+			if err := tx.Create(&opening).Error; err != nil {
+				return err
+			}
+			for _, surgery := range surgeries {
+				balance -= surgery.DepositDeducted
+				hold := models.DepositTransaction{
+					PatientID:         patient.ID,
+					SurgeryScheduleID: &surgery.ID,
+					Type:              models.DepositHold,
+					Amount:            surgery.DepositDeducted,
+					BalanceAfter:      balance,
+					Note:              "Opening hold",
+				}
+				if err := tx.Create(&hold).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("InitializeDatabase: Failed to backfill ledger for patient %d - %v", patient.ID, err)
+		}
+	}
+}
+
+// moneyColumnMigration marks a money column whose values were rescaled to
+// minor units, so a restart before its type change does not rescale it again.
+type moneyColumnMigration struct {
+	Name      string `gorm:"primaryKey;size:100"`
+	CreatedAt time.Time
+}
+
+// convertMoneyColumnsToMinorUnits turns money columns that are still stored
+// as floating point into integer minor units. The rescale is recorded in the
+// same transaction as a marker and the column type is changed right after,
+// so each column is multiplied by 100 exactly once.
+func convertMoneyColumnsToMinorUnits() {
+	columns := []struct {
+		model  interface{}
+		table  string
+		column string
+		field  string
+	}{
+		{&models.Patient{}, "patients", "deposit", "Deposit"},
+		{&models.SurgerySchedule{}, "surgery_schedules", "deposit_deducted", "DepositDeducted"},
+		{&models.DepositTransaction{}, "deposit_transactions", "amount", "Amount"},
+		{&models.DepositTransaction{}, "deposit_transactions", "balance_after", "BalanceAfter"},
+	}
+
+	if err := config.DB.AutoMigrate(&moneyColumnMigration{}); err != nil {
+		log.Fatalf("InitializeDatabase: Failed to create money migration markers - %v", err)
+	}
+
+	migrator := config.DB.Migrator()
+	for _, col := range columns {
+		if !migrator.HasTable(col.model) {
+			continue
+		}
+		columnTypes, err := migrator.ColumnTypes(col.model)
+		if err != nil {
+			log.Fatalf("InitializeDatabase: Failed to inspect %s - %v", col.table, err)
+		}
+		for _, columnType := range columnTypes {
+			if columnType.Name() != col.column {
+				continue
+			}
+			typeName := strings.ToLower(columnType.DatabaseTypeName())
+			if typeName != "double" && typeName != "float" && typeName != "decimal" {
+				continue
+			}
+
+			marker := col.table + "." + col.column
+			err := config.DB.Transaction(func(tx *gorm.DB) error {
+				var done int64
+				if err := tx.Model(&moneyColumnMigration{}).Where("name = ?", marker).Count(&done).Error; err != nil {
+					return err
+				}
+				if done > 0 {
+					log.Printf("InitializeDatabase: %s already rescaled, only changing its type", marker)
+					return nil
+				}
+				if err := tx.Exec("UPDATE " + col.table + " SET " + col.column + " = ROUND(" + col.column + " * 100)").Error; err != nil {
+					return err
+				}
+				return tx.Create(&moneyColumnMigration{Name: marker}).Error
+			})
+			if err != nil {
+				log.Fatalf("InitializeDatabase: Failed to convert %s to minor units - %v", marker, err)
+			}
+			if err := migrator.AlterColumn(col.model, col.field); err != nil {
+				log.Fatalf("InitializeDatabase: Failed to change the type of %s - %v", marker, err)
+			}
+			log.Printf("InitializeDatabase: Converted %s to minor units", marker)
+		}
+	}
+}
+
+func backfillCurrencies() {
+	for _, table := range []string{"patients", "surgery_schedules", "deposit_transactions", "invoices", "payments"} {
+		if err := config.DB.Exec("UPDATE "+table+" SET currency = ? WHERE currency IS NULL OR currency = ''", config.Currency()).Error; err != nil {
+			log.Printf("InitializeDatabase: Failed to backfill currency for %s - %v", table, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AppointmentStatus string
+
+const (
+	AppointmentStatusBooked    AppointmentStatus = "Booked"
+	AppointmentStatusCheckedIn AppointmentStatus = "Checked In"
+	AppointmentStatusSeen      AppointmentStatus = "Seen"
+	AppointmentStatusNoShow    AppointmentStatus = "No Show"
+	AppointmentStatusCancelled AppointmentStatus = "Cancelled"
+)
+
+// InactiveAppointmentStatuses no longer reserve the doctor's time.
+var InactiveAppointmentStatuses = []AppointmentStatus{
+	AppointmentStatusSeen,
+	AppointmentStatusNoShow,
+	AppointmentStatusCancelled,
+}
+
+var ErrInvalidAppointmentTransition = errors.New("invalid appointment status transition")
+
+var appointmentTransitions = map[AppointmentStatus][]AppointmentStatus{
+	AppointmentStatusBooked: {
+		AppointmentStatusCheckedIn,
+		AppointmentStatusNoShow,
+		AppointmentStatusCancelled,
+	},
+	AppointmentStatusCheckedIn: {AppointmentStatusSeen, AppointmentStatusCancelled},
+}
+
+func (s AppointmentStatus) CanTransitionTo(next AppointmentStatus) bool {
+	for _, allowed := range appointmentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Appointment is an outpatient consultation booked in the doctor's rostered
+// time. Duration is expressed in minutes.
+type Appointment struct {
+	gorm.Model
+	PatientID    uint              `json:"patient_id" gorm:"index"`
+	Patient      Patient           `json:"patient" gorm:"foreignKey:PatientID"`
+	DoctorID     uint              `json:"doctor_id" gorm:"index"`
+	Doctor       Doctor            `json:"doctor" gorm:"foreignKey:DoctorID"`
+	ScheduledAt  time.Time         `json:"scheduled_at" gorm:"index"`
+	ScheduledEnd time.Time         `json:"scheduled_end" gorm:"index"`
+	Duration     int               `json:"duration"`
+	Reason       string            `json:"reason"`
+	Notes        string            `json:"notes"`
+	Status       AppointmentStatus `json:"status" gorm:"size:20;default:'Booked'"`
+	CheckedInAt  *time.Time        `json:"checked_in_at"`
+	SeenAt       *time.Time        `json:"seen_at"`
+	CancelledAt  *time.Time        `json:"cancelled_at"`
+}
+
+func (a *Appointment) Slot() TimeSlot {
+	return TimeSlot{
+		Start: a.ScheduledAt,
+		End:   a.ScheduledAt.Add(time.Duration(a.Duration) * time.Minute),
+	}
+}
+
+// BeforeSave keeps ScheduledEnd in sync so overlap checks can be done in SQL.
+func (a *Appointment) BeforeSave(tx *gorm.DB) error {
+	a.ScheduledEnd = a.Slot().End
+	return nil
+}
+
+// TransitionTo moves the appointment to next and records when it happened.
+// Every status change of an Appointment must go through here.
+func (a *Appointment) TransitionTo(next AppointmentStatus, at time.Time) error {
+	if !a.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: cannot move appointment %d from %s to %s", ErrInvalidAppointmentTransition, a.ID, a.Status, next)
+	}
+
+	switch next {
+	case AppointmentStatusCheckedIn:
+		a.CheckedInAt = &at
+	case AppointmentStatusSeen:
+		a.SeenAt = &at
+	case AppointmentStatusCancelled:
+		a.CancelledAt = &at
+	}
+	a.Status = next
+	return nil
+}
+
+type AppointmentRequest struct {
+	PatientID   uint      `json:"patient_id" binding:"required"`
+	DoctorID    uint      `json:"doctor_id" binding:"required"`
+	ScheduledAt time.Time `json:"scheduled_at" binding:"required"`
+	Duration    int       `json:"duration" binding:"gte=0"`
+	Reason      string    `json:"reason"`
+	Notes       string    `json:"notes"`
+}
+
+type AppointmentRescheduleRequest struct {
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	Duration    *int       `json:"duration" binding:"omitempty,gt=0"`
+	DoctorID    *uint      `json:"doctor_id"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "gorm.io/gorm"
+
+// CancellationPolicyRule refunds RefundPercent of the held deposit when a
+// surgery is cancelled at least MinHoursBefore hours before its start. Rules
+// without a SurgeryTypeID are the default for types that have none of their own.
+type CancellationPolicyRule struct {
+	gorm.Model
+	SurgeryTypeID  *uint  `json:"surgery_type_id" gorm:"index"`
+	Name           string `json:"name" binding:"required"`
+	MinHoursBefore int    `json:"min_hours_before"`
+	RefundPercent  int    `json:"refund_percent" binding:"gte=0,lte=100"`
+}
+
+// SelectCancellationRule picks the rule with the highest threshold that the
+// cancellation still meets, or nil when it is too late for every rule.
+func SelectCancellationRule(rules []CancellationPolicyRule, hoursBefore float64) *CancellationPolicyRule {
+	var selected *CancellationPolicyRule
+	for i := range rules {
+		rule := &rules[i]
+		if hoursBefore < float64(rule.MinHoursBefore) {
+			continue
+		}
+		if selected == nil || rule.MinHoursBefore > selected.MinHoursBefore {
+			selected = rule
+		}
+	}
+	return selected
+}
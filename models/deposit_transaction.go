@@ -0,0 +1,52 @@
+package models
+
+import "gorm.io/gorm"
+
+type DepositTransactionType string
+
+const (
+	DepositTopUp      DepositTransactionType = "TopUp"
+	DepositHold       DepositTransactionType = "Hold"
+	DepositCapture    DepositTransactionType = "Capture"
+	DepositRefund     DepositTransactionType = "Refund"
+	DepositAdjustment DepositTransactionType = "Adjustment"
+)
+
+// DepositTransaction is an append-only ledger entry. A patient's available
+// deposit is the sum of the balance effects of all their entries.
+type DepositTransaction struct {
+	gorm.Model
+	PatientID         uint                   `json:"patient_id" gorm:"index"`
+	SurgeryScheduleID *uint                  `json:"surgery_schedule_id" gorm:"index"`
+	Type              DepositTransactionType `json:"type"`
+	Amount            Amount                 `json:"amount"`
+	BalanceAfter      Amount                 `json:"balance_after"`
+	Currency          string                 `json:"currency" gorm:"size:3"`
+	Note              string                 `json:"note"`
+}
+
+// BalanceEffect is the change the entry makes to the available deposit.
+// Holds move money out of the available balance, refunds give it back and
+// captures settle held money without touching the available balance.
+func (t DepositTransaction) BalanceEffect() Amount {
+	switch t.Type {
+	case DepositHold:
+		return -t.Amount
+	case DepositCapture:
+		return 0
+	default:
+		return t.Amount
+	}
+}
+
+// DepositBalanceSQL sums BalanceEffect over deposit_transactions rows.
+const DepositBalanceSQL = "COALESCE(SUM(CASE type WHEN 'Hold' THEN -amount WHEN 'Capture' THEN 0 ELSE amount END), 0)"
+
+// DepositHeldSQL sums the amount still held against a surgery.
+const DepositHeldSQL = "COALESCE(SUM(CASE type WHEN 'Hold' THEN amount WHEN 'Refund' THEN -amount WHEN 'Capture' THEN -amount ELSE 0 END), 0)"
+
+type DepositRequest struct {
+	Amount   Amount `json:"amount" binding:"required"`
+	Currency string `json:"currency"`
+	Note     string `json:"note"`
+}
@@ -4,7 +4,10 @@ import "gorm.io/gorm"
 
 type Doctor struct {
 	gorm.Model
-	Name      string `json:"name"`
-	ContactNo string `json:"contact_no"`
-	Address   string `json:"address"`
+	Name       string `json:"name"`
+	ContactNo  string `json:"contact_no"`
+	Address    string `json:"address"`
+	SurgeryFee Amount `json:"surgery_fee"`
+
+	Specialties []Specialty `json:"specialties" gorm:"many2many:doctor_specialties"`
 }
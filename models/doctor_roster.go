@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ShiftKind string
+
+const (
+	ShiftRegular ShiftKind = "Regular"
+	ShiftOnCall  ShiftKind = "On Call"
+)
+
+func (k ShiftKind) IsValid() bool {
+	return k == ShiftRegular || k == ShiftOnCall
+}
+
+// DoctorWorkingHours is a weekly recurring block of rostered time, e.g.
+// Mondays 09:00-17:00 local time. Overnight cover is rostered as a shift.
+type DoctorWorkingHours struct {
+	gorm.Model
+	DoctorID  uint          `json:"doctor_id" gorm:"index"`
+	Weekday   *time.Weekday `json:"weekday" gorm:"not null" binding:"required,min=0,max=6"`
+	StartTime string        `json:"start_time" gorm:"size:5" binding:"required"`
+	EndTime   string        `json:"end_time" gorm:"size:5" binding:"required"`
+}
+
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+func (h DoctorWorkingHours) Validate() error {
+	start, err := parseClock(h.StartTime)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(h.EndTime)
+	if err != nil {
+		return err
+	}
+	if end <= start {
+		return fmt.Errorf("end_time %s must be after start_time %s", h.EndTime, h.StartTime)
+	}
+	return nil
+}
+
+// SlotOn returns the block for the calendar day of date, or false when the
+// block does not apply to that weekday.
+func (h DoctorWorkingHours) SlotOn(date time.Time) (TimeSlot, bool) {
+	if h.Weekday == nil || date.Weekday() != *h.Weekday {
+		return TimeSlot{}, false
+	}
+	start, err := parseClock(h.StartTime)
+	if err != nil {
+		return TimeSlot{}, false
+	}
+	end, err := parseClock(h.EndTime)
+	if err != nil {
+		return TimeSlot{}, false
+	}
+	y, m, d := date.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	return TimeSlot{Start: midnight.Add(start), End: midnight.Add(end)}, true
+}
+
+// DoctorShift is rostered time on a specific date on top of the weekly
+// working hours, such as an on-call night.
+type DoctorShift struct {
+	gorm.Model
+	DoctorID uint      `json:"doctor_id" gorm:"index"`
+	Kind     ShiftKind `json:"kind"`
+	StartAt  time.Time `json:"start_at" binding:"required"`
+	EndAt    time.Time `json:"end_at" binding:"required"`
+	Notes    string    `json:"notes"`
+}
+
+func (s DoctorShift) Slot() TimeSlot {
+	return TimeSlot{Start: s.StartAt, End: s.EndAt}
+}
+
+// DoctorLeave blocks out a period in which the doctor cannot be booked,
+// whatever the roster says.
+type DoctorLeave struct {
+	gorm.Model
+	DoctorID uint      `json:"doctor_id" gorm:"index"`
+	StartAt  time.Time `json:"start_at" binding:"required"`
+	EndAt    time.Time `json:"end_at" binding:"required"`
+	Reason   string    `json:"reason"`
+}
+
+func (l DoctorLeave) Slot() TimeSlot {
+	return TimeSlot{Start: l.StartAt, End: l.EndAt}
+}
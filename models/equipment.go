@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Equipment is either installed in a theater or, when Movable, a shared unit
+// that has to be booked for each surgery. OperatingTheaterID is the theater
+// installed equipment belongs to, or the home of a movable unit.
+type Equipment struct {
+	gorm.Model
+	Name               string `json:"name" binding:"required"`
+	Movable            bool   `json:"movable"`
+	OperatingTheaterID *uint  `json:"operating_theater_id" gorm:"index"`
+}
+
+// EquipmentBooking reserves a movable unit for a surgery. A booking stops
+// counting once its surgery is no longer active.
+type EquipmentBooking struct {
+	gorm.Model
+	EquipmentID       uint      `json:"equipment_id" gorm:"index"`
+	Equipment         Equipment `json:"equipment" gorm:"foreignKey:EquipmentID"`
+	SurgeryScheduleID uint      `json:"surgery_schedule_id" gorm:"index"`
+	StartAt           time.Time `json:"start_at"`
+	EndAt             time.Time `json:"end_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "gorm.io/gorm"
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatusOpen InvoiceStatus = "Open"
+	InvoiceStatusPaid InvoiceStatus = "Paid"
+)
+
+type InvoiceLineKind string
+
+const (
+	InvoiceLineBasePrice  InvoiceLineKind = "BasePrice"
+	InvoiceLineTheater    InvoiceLineKind = "TheaterTime"
+	InvoiceLineDoctorFee  InvoiceLineKind = "DoctorFee"
+	InvoiceLineConsumable InvoiceLineKind = "Consumable"
+)
+
+type Invoice struct {
+	gorm.Model
+	PatientID         uint          `json:"patient_id" gorm:"index"`
+	SurgeryScheduleID uint          `json:"surgery_schedule_id" gorm:"uniqueIndex"`
+	Status            InvoiceStatus `json:"status" gorm:"default:'Open'"`
+	Currency          string        `json:"currency" gorm:"size:3"`
+	Subtotal          Amount        `json:"subtotal"`
+	DepositApplied    Amount        `json:"deposit_applied"`
+	AmountPaid        Amount        `json:"amount_paid"`
+	Outstanding       Amount        `json:"outstanding"`
+	Lines             []InvoiceLine `json:"lines" gorm:"foreignKey:InvoiceID"`
+	Payments          []Payment     `json:"payments" gorm:"foreignKey:InvoiceID"`
+}
+
+type InvoiceLine struct {
+	gorm.Model
+	InvoiceID   uint            `json:"invoice_id" gorm:"index"`
+	Kind        InvoiceLineKind `json:"kind"`
+	Description string          `json:"description"`
+	Quantity    int             `json:"quantity"`
+	UnitPrice   Amount          `json:"unit_price"`
+	Amount      Amount          `json:"amount"`
+}
+
+type Payment struct {
+	gorm.Model
+	InvoiceID uint   `json:"invoice_id" gorm:"index"`
+	PatientID uint   `json:"patient_id" gorm:"index"`
+	Amount    Amount `json:"amount" binding:"required,gt=0"`
+	Currency  string `json:"currency" gorm:"size:3"`
+	Method    string `json:"method" binding:"required"`
+	Reference string `json:"reference"`
+}
+
+// Settle recomputes the outstanding balance and status from the totals.
+func (i *Invoice) Settle() {
+	i.Outstanding = i.Subtotal - i.DepositApplied - i.AmountPaid
+	if i.Outstanding <= 0 {
+		i.Status = InvoiceStatusPaid
+	} else {
+		i.Status = InvoiceStatusOpen
+	}
+}
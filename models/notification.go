@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification is a message for a doctor about a change to one of their
+// surgeries that they did not make themselves.
+type Notification struct {
+	gorm.Model
+	DoctorID          uint       `json:"doctor_id" gorm:"index"`
+	SurgeryScheduleID *uint      `json:"surgery_schedule_id" gorm:"index"`
+	Message           string     `json:"message"`
+	ReadAt            *time.Time `json:"read_at"`
+}
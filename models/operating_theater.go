@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type OTStatus string
 
@@ -12,8 +16,26 @@ const (
 
 type OperatingTheater struct {
 	gorm.Model
-	Name     string   `json:"name"`
-	Floor    int      `json:"floor"`
-	Status   OTStatus `json:"status" gorm:"default:'Available'"`
-	Capacity int      `json:"capacity"`
+	Name       string      `json:"name"`
+	Floor      int         `json:"floor"`
+	Status     OTStatus    `json:"status" gorm:"default:'Available'"`
+	Capacity   int         `json:"capacity"`
+	Class      string      `json:"class"`
+	HourlyRate Amount      `json:"hourly_rate"`
+	Equipment  []Equipment `json:"equipment" gorm:"foreignKey:OperatingTheaterID"`
+
+	// TurnoverMinutes overrides the hospital-wide cleaning interval when set.
+	TurnoverMinutes *int `json:"turnover_minutes"`
+}
+
+func (s OTStatus) IsValid() bool {
+	return s == OTStatusAvailable || s == OTStatusOccupied || s == OTStatusMaintenance
+}
+
+// Turnover is the time the theater stays blocked after each surgery.
+func (ot OperatingTheater) Turnover(hospitalDefault time.Duration) time.Duration {
+	if ot.TurnoverMinutes != nil {
+		return time.Duration(*ot.TurnoverMinutes) * time.Minute
+	}
+	return hospitalDefault
 }
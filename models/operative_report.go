@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DischargeDestination string
+
+const (
+	DischargeRecoveryWard DischargeDestination = "Recovery Ward"
+	DischargeICU          DischargeDestination = "ICU"
+	DischargeHDU          DischargeDestination = "HDU"
+	DischargeWard         DischargeDestination = "Ward"
+	DischargeHome         DischargeDestination = "Home"
+	DischargeTransferred  DischargeDestination = "Transferred"
+	DischargeDeceased     DischargeDestination = "Deceased"
+)
+
+func (d DischargeDestination) IsValid() bool {
+	switch d {
+	case DischargeRecoveryWard, DischargeICU, DischargeHDU, DischargeWard, DischargeHome, DischargeTransferred, DischargeDeceased:
+		return true
+	}
+	return false
+}
+
+// OperativeReport is written when a surgery is completed. Durations are in
+// minutes; DurationVariance is actual minus estimated, so overruns are
+// positive.
+type OperativeReport struct {
+	gorm.Model
+	SurgeryScheduleID    uint                 `json:"surgery_schedule_id" gorm:"uniqueIndex"`
+	ProcedurePerformed   string               `json:"procedure_performed"`
+	Findings             string               `json:"findings"`
+	Complications        []string             `json:"complications" gorm:"serializer:json"`
+	BloodLossML          int                  `json:"blood_loss_ml"`
+	DischargeDestination DischargeDestination `json:"discharge_destination" gorm:"size:32"`
+	Notes                string               `json:"notes"`
+	ReportedBy           string               `json:"reported_by"`
+
+	ActualStartAt     time.Time `json:"actual_start_at"`
+	ActualEndAt       time.Time `json:"actual_end_at"`
+	ActualDuration    int       `json:"actual_duration"`
+	EstimatedDuration int       `json:"estimated_duration"`
+	DurationVariance  int       `json:"duration_variance"`
+}
+
+// OperativeReportRequest is the optional body of a completion. ActualEndAt
+// corrects the end time when the report is filed after the surgery ended.
+type OperativeReportRequest struct {
+	ProcedurePerformed   string               `json:"procedure_performed"`
+	Findings             string               `json:"findings"`
+	Complications        []string             `json:"complications"`
+	BloodLossML          int                  `json:"blood_loss_ml" binding:"gte=0"`
+	DischargeDestination DischargeDestination `json:"discharge_destination"`
+	Notes                string               `json:"notes"`
+	ReportedBy           string               `json:"reported_by"`
+	ActualEndAt          *time.Time           `json:"actual_end_at"`
+}
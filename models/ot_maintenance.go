@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OTMaintenanceWindow is planned downtime for a theater. The scheduler treats
+// it as blocked time; the Maintenance status is for unplanned outages.
+type OTMaintenanceWindow struct {
+	gorm.Model
+	OperatingTheaterID uint      `json:"operating_theater_id" gorm:"index"`
+	StartAt            time.Time `json:"start_at" binding:"required"`
+	EndAt              time.Time `json:"end_at" binding:"required"`
+	Reason             string    `json:"reason"`
+}
+
+func (w OTMaintenanceWindow) Slot() TimeSlot {
+	return TimeSlot{Start: w.StartAt, End: w.EndAt}
+}
@@ -4,9 +4,10 @@ import "gorm.io/gorm"
 
 type Patient struct {
 	gorm.Model
-	Name      string  `json:"name"`
-	ContactNo string  `json:"contact_no"`
-	Address   string  `json:"address"`
-	DoctorID  uint    `json:"doctor_id"`
-	Deposit   float64 `json:"deposit" gorm:"default:0"`
+	Name      string `json:"name"`
+	ContactNo string `json:"contact_no"`
+	Address   string `json:"address"`
+	DoctorID  uint   `json:"doctor_id"`
+	Deposit   Amount `json:"deposit" gorm:"default:0"` // cached ledger balance, see DepositTransaction
+	Currency  string `json:"currency" gorm:"size:3"`
 }
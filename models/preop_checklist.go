@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChecklistTemplateItem is a pre-operative check copied onto every surgery of
+// its surgery type when it is booked. Items without a SurgeryTypeID apply to
+// every surgery type.
+type ChecklistTemplateItem struct {
+	gorm.Model
+	SurgeryTypeID *uint  `json:"surgery_type_id" gorm:"index"`
+	Name          string `json:"name" binding:"required"`
+	Category      string `json:"category"`
+	Mandatory     bool   `json:"mandatory"`
+	Position      int    `json:"position"`
+}
+
+// SurgeryChecklistItem is a pre-operative check of one surgery. Mandatory
+// items have to be completed before the surgery can start, unless the
+// surgery's priority waives the checklist.
+type SurgeryChecklistItem struct {
+	gorm.Model
+	SurgeryScheduleID uint       `json:"surgery_schedule_id" gorm:"index"`
+	TemplateItemID    *uint      `json:"template_item_id"`
+	Name              string     `json:"name"`
+	Category          string     `json:"category"`
+	Mandatory         bool       `json:"mandatory"`
+	Position          int        `json:"position"`
+	CompletedAt       *time.Time `json:"completed_at"`
+	CompletedBy       string     `json:"completed_by"`
+	Notes             string     `json:"notes"`
+}
+
+func (item SurgeryChecklistItem) Outstanding() bool {
+	return item.Mandatory && item.CompletedAt == nil
+}
+
+// SurgeryChecklistUpdate ticks an item off, or reopens it with Completed false.
+type SurgeryChecklistUpdate struct {
+	Completed   *bool   `json:"completed" binding:"required"`
+	CompletedBy string  `json:"completed_by"`
+	Notes       *string `json:"notes"`
+}
@@ -0,0 +1,64 @@
+package models
+
+// ScheduleOptimizationRequest asks for one day's list to be built from pending
+// requests. Without Commit the plan is only previewed.
+type ScheduleOptimizationRequest struct {
+	// Date is the day to plan, as YYYY-MM-DD. It defaults to tomorrow.
+	Date               string                   `json:"date"`
+	Requests           []SurgeryScheduleRequest `json:"requests"`
+	WaitlistEntryIDs   []uint                   `json:"waitlist_entry_ids"`
+	MaxOvertimeMinutes *int                     `json:"max_overtime_minutes" binding:"omitempty,gte=0"`
+	StepMinutes        int                      `json:"step_minutes" binding:"omitempty,gte=5"`
+	Commit             bool                     `json:"commit"`
+}
+
+// ScheduleAssignment places one request in a theater at a time.
+type ScheduleAssignment struct {
+	TimeSlot
+	// Index is the position of the request in Requests, or nil for a
+	// waitlist entry.
+	Index              *int            `json:"index,omitempty"`
+	WaitlistEntryID    *uint           `json:"waitlist_entry_id,omitempty"`
+	PatientID          uint            `json:"patient_id"`
+	DoctorID           uint            `json:"doctor_id"`
+	SurgeryType        string          `json:"surgery_type"`
+	Priority           SurgeryPriority `json:"priority"`
+	OperatingTheaterID uint            `json:"operating_theater_id"`
+	MovableEquipment   []string        `json:"movable_equipment,omitempty"`
+	OvertimeMinutes    int             `json:"overtime_minutes"`
+	// SurgeryScheduleID is only set once the plan is committed.
+	SurgeryScheduleID *uint `json:"surgery_schedule_id,omitempty"`
+}
+
+// UnscheduledRequest is a request the optimizer could not place.
+type UnscheduledRequest struct {
+	Index           *int   `json:"index,omitempty"`
+	WaitlistEntryID *uint  `json:"waitlist_entry_id,omitempty"`
+	PatientID       uint   `json:"patient_id"`
+	DoctorID        uint   `json:"doctor_id"`
+	SurgeryType     string `json:"surgery_type"`
+	Reason          string `json:"reason"`
+}
+
+// TheaterUtilization summarizes a theater's day once the plan is applied.
+// Minutes are counted within the working day, overtime after it.
+type TheaterUtilization struct {
+	OperatingTheaterID   uint   `json:"operating_theater_id"`
+	OperatingTheaterName string `json:"operating_theater_name"`
+	Surgeries            int    `json:"surgeries"`
+	BookedMinutes        int    `json:"booked_minutes"`
+	IdleMinutes          int    `json:"idle_minutes"`
+	OvertimeMinutes      int    `json:"overtime_minutes"`
+}
+
+type ScheduleOptimizationResult struct {
+	Date        string               `json:"date"`
+	Day         TimeSlot             `json:"day"`
+	Committed   bool                 `json:"committed"`
+	Assignments []ScheduleAssignment `json:"assignments"`
+	Unscheduled []UnscheduledRequest `json:"unscheduled"`
+	Theaters    []TheaterUtilization `json:"theaters"`
+
+	TotalIdleMinutes     int `json:"total_idle_minutes"`
+	TotalOvertimeMinutes int `json:"total_overtime_minutes"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// SlotSuggestion is a start time at which a surgery could be booked, with the
+// doctor and theater the booking would use.
+type SlotSuggestion struct {
+	TimeSlot
+	DoctorID             uint     `json:"doctor_id"`
+	DoctorName           string   `json:"doctor_name"`
+	OperatingTheaterID   uint     `json:"operating_theater_id"`
+	OperatingTheaterName string   `json:"operating_theater_name"`
+	MovableEquipment     []string `json:"movable_equipment,omitempty"`
+}
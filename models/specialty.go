@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+type Specialty struct {
+	gorm.Model
+	Name string `json:"name" gorm:"size:100;uniqueIndex" binding:"required"`
+}
+
+// DoctorPrivilege credentials a doctor to lead a given surgery type.
+type DoctorPrivilege struct {
+	gorm.Model
+	DoctorID      uint        `json:"doctor_id" gorm:"uniqueIndex:idx_doctor_privilege"`
+	SurgeryTypeID uint        `json:"surgery_type_id" gorm:"uniqueIndex:idx_doctor_privilege" binding:"required"`
+	SurgeryType   SurgeryType `json:"surgery_type" gorm:"foreignKey:SurgeryTypeID"`
+}
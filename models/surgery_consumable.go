@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+type SurgeryConsumable struct {
+	gorm.Model
+	SurgeryScheduleID uint   `json:"surgery_schedule_id" gorm:"index"`
+	Name              string `json:"name" binding:"required"`
+	Quantity          int    `json:"quantity" binding:"required,gt=0"`
+	UnitPrice         Amount `json:"unit_price" binding:"gte=0"`
+}
@@ -0,0 +1,47 @@
+package models
+
+type SurgeryPriority string
+
+const (
+	SurgeryPriorityElective  SurgeryPriority = "Elective"
+	SurgeryPriorityUrgent    SurgeryPriority = "Urgent"
+	SurgeryPriorityEmergency SurgeryPriority = "Emergency"
+)
+
+func (p SurgeryPriority) IsValid() bool {
+	switch p {
+	case SurgeryPriorityElective, SurgeryPriorityUrgent, SurgeryPriorityEmergency:
+		return true
+	}
+	return false
+}
+
+func (p SurgeryPriority) rank() int {
+	switch p {
+	case SurgeryPriorityUrgent:
+		return 1
+	case SurgeryPriorityEmergency:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Preempts reports whether a surgery of priority p may bump one of other out
+// of its operating theater.
+func (p SurgeryPriority) Preempts(other SurgeryPriority) bool {
+	return p.rank() > other.rank()
+}
+
+// DefersBilling reports whether the deposit is skipped and the surgery is
+// billed afterwards.
+func (p SurgeryPriority) DefersBilling() bool {
+	return p == SurgeryPriorityEmergency
+}
+
+// WaivesChecklist reports whether the surgery may start with mandatory pre-op
+// checks still open. Emergencies cannot wait for them; what was skipped is
+// reported when the surgery starts.
+func (p SurgeryPriority) WaivesChecklist() bool {
+	return p == SurgeryPriorityEmergency
+}
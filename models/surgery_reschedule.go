@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SurgeryReschedule struct {
+	gorm.Model
+	SurgeryScheduleID          uint      `json:"surgery_schedule_id" gorm:"index"`
+	PreviousScheduledAt        time.Time `json:"previous_scheduled_at"`
+	PreviousEstimatedDuration  int       `json:"previous_estimated_duration"`
+	PreviousDoctorID           uint      `json:"previous_doctor_id"`
+	PreviousOperatingTheaterID uint      `json:"previous_operating_theater_id"`
+	NewScheduledAt             time.Time `json:"new_scheduled_at"`
+	NewEstimatedDuration       int       `json:"new_estimated_duration"`
+	NewDoctorID                uint      `json:"new_doctor_id"`
+	NewOperatingTheaterID      uint      `json:"new_operating_theater_id"`
+	Reason                     string    `json:"reason"`
+}
+
+type SurgeryRescheduleRequest struct {
+	ScheduledAt        *time.Time `json:"scheduled_at"`
+	EstimatedDuration  *int       `json:"estimated_duration" binding:"omitempty,gt=0"`
+	DoctorID           *uint      `json:"doctor_id"`
+	OperatingTheaterID *uint      `json:"operating_theater_id"`
+	Reason             string     `json:"reason" binding:"required"`
+}
@@ -13,22 +13,99 @@ const (
 	SurgeryStatusInProgress SurgeryStatus = "In Progress"
 	SurgeryStatusCompleted  SurgeryStatus = "Completed"
 	SurgeryStatusCancelled  SurgeryStatus = "Cancelled"
+	SurgeryStatusPostponed  SurgeryStatus = "Postponed"
+	SurgeryStatusNoShow     SurgeryStatus = "No Show"
 )
 
+// InactiveSurgeryStatuses no longer reserve a doctor or an operating theater.
+var InactiveSurgeryStatuses = []SurgeryStatus{
+	SurgeryStatusCompleted,
+	SurgeryStatusCancelled,
+	SurgeryStatusPostponed,
+	SurgeryStatusNoShow,
+}
+
 type SurgerySchedule struct {
 	gorm.Model
 	PatientID          uint             `json:"patient_id"`
 	Patient            Patient          `json:"patient" gorm:"foreignKey:PatientID"`
 	DoctorID           uint             `json:"doctor_id"`
 	Doctor             Doctor           `json:"doctor" gorm:"foreignKey:DoctorID"`
-	OperatingTheaterID uint             `json:"operating_theater_id"`
+	OperatingTheaterID uint             `json:"operating_theater_id" gorm:"index"`
 	OperatingTheater   OperatingTheater `json:"operating_theater" gorm:"foreignKey:OperatingTheaterID"`
 	SurgeryType        string           `json:"surgery_type"`
-	ScheduledAt        time.Time        `json:"scheduled_at"`
+	ScheduledAt        time.Time        `json:"scheduled_at" gorm:"index"`
+	ScheduledEnd       time.Time        `json:"scheduled_end" gorm:"index"`
 	EstimatedDuration  int              `json:"estimated_duration"`
-	DepositDeducted    float64          `json:"deposit_deducted"`
+	DepositDeducted    Amount           `json:"deposit_deducted"`
+	Currency           string           `json:"currency" gorm:"size:3"`
 	Status             SurgeryStatus    `json:"status" gorm:"default:'Scheduled'"`
+	Priority           SurgeryPriority  `json:"priority" gorm:"size:20;default:'Elective'"`
+	ActualStartAt      *time.Time       `json:"actual_start_at"`
+	ActualEndAt        *time.Time       `json:"actual_end_at"`
+	CancelledAt        *time.Time       `json:"cancelled_at"`
+	CancellationRule   string           `json:"cancellation_rule"`
+	CancellationFee    Amount           `json:"cancellation_fee"`
+	CancellationRefund Amount           `json:"cancellation_refund"`
 	Notes              string           `json:"notes"`
+	RequiredEquipment  []string         `json:"required_equipment" gorm:"serializer:json"`
+
+	// MinCapacity and RequiredFloor keep the theater constraints of the
+	// original request for when the surgery has to change theaters.
+	MinCapacity   int  `json:"min_capacity,omitempty"`
+	RequiredFloor *int `json:"required_floor,omitempty"`
+
+	// DeferredBilling marks surgeries booked without a deposit, to be billed
+	// in full on completion.
+	DeferredBilling bool `json:"deferred_billing"`
+	// DisplacedBySurgeryID is set when a higher-priority surgery bumped this
+	// one out of its theater.
+	DisplacedBySurgeryID *uint `json:"displaced_by_surgery_id"`
+	// SurgerySeriesID links the stages of a staged or recurring treatment,
+	// SeriesStage numbering them from 1.
+	SurgerySeriesID *uint `json:"surgery_series_id" gorm:"index"`
+	SeriesStage     int   `json:"series_stage,omitempty"`
+	// PostOpWardID asks for a bed in that ward to be held from the planned
+	// end of the surgery for PostOpNights nights.
+	PostOpWardID *uint `json:"post_op_ward_id"`
+	PostOpNights int   `json:"post_op_nights,omitempty"`
+
+	Team              []SurgicalTeamMember   `json:"team,omitempty" gorm:"foreignKey:SurgeryScheduleID"`
+	Reschedules       []SurgeryReschedule    `json:"reschedules,omitempty" gorm:"foreignKey:SurgeryScheduleID"`
+	EquipmentBookings []EquipmentBooking     `json:"equipment_bookings,omitempty" gorm:"foreignKey:SurgeryScheduleID"`
+	Checklist         []SurgeryChecklistItem `json:"checklist,omitempty" gorm:"foreignKey:SurgeryScheduleID"`
+	OperativeReport   *OperativeReport       `json:"operative_report,omitempty" gorm:"foreignKey:SurgeryScheduleID"`
+	PostOpBed         *BedReservation        `json:"post_op_bed,omitempty" gorm:"foreignKey:SurgeryScheduleID"`
+
+	// OutstandingChecklist lists the mandatory checks still open. It is only
+	// filled in where the checklist is shown.
+	OutstandingChecklist []SurgeryChecklistItem `json:"outstanding_checklist,omitempty" gorm:"-"`
+}
+
+func (s *SurgerySchedule) Slot() TimeSlot {
+	return TimeSlot{
+		Start: s.ScheduledAt,
+		End:   s.ScheduledAt.Add(time.Duration(s.EstimatedDuration) * time.Minute),
+	}
+}
+
+// OutstandingChecklistItems returns the mandatory items of the loaded
+// checklist that are not completed yet.
+func (s *SurgerySchedule) OutstandingChecklistItems() []SurgeryChecklistItem {
+	outstanding := []SurgeryChecklistItem{}
+	for _, item := range s.Checklist {
+		if item.Outstanding() {
+			outstanding = append(outstanding, item)
+		}
+	}
+	return outstanding
+}
+
+// BeforeSave keeps ScheduledEnd in sync so overlap checks can be done in SQL.
+// EstimatedDuration is expressed in minutes.
+func (s *SurgerySchedule) BeforeSave(tx *gorm.DB) error {
+	s.ScheduledEnd = s.Slot().End
+	return nil
 }
 
 type SurgeryScheduleRequest struct {
@@ -36,7 +113,28 @@ type SurgeryScheduleRequest struct {
 	DoctorID          uint      `json:"doctor_id" binding:"required"`
 	SurgeryType       string    `json:"surgery_type" binding:"required"`
 	ScheduledAt       time.Time `json:"scheduled_at" binding:"required"`
-	EstimatedDuration int       `json:"estimated_duration" binding:"required"`
-	DepositRequired   float64   `json:"deposit_required" binding:"required"`
+	EstimatedDuration int       `json:"estimated_duration" binding:"gte=0"`
+	DepositRequired   Amount    `json:"deposit_required" binding:"gte=0"`
+	Currency          string    `json:"currency"`
 	Notes             string    `json:"notes"`
+
+	Priority SurgeryPriority `json:"priority"`
+
+	OperatingTheaterID *uint    `json:"operating_theater_id"`
+	MinCapacity        int      `json:"min_capacity" binding:"gte=0"`
+	Floor              *int     `json:"floor"`
+	RequiredEquipment  []string `json:"required_equipment"`
+
+	Team []TeamMemberRequest `json:"team" binding:"dive"`
+
+	// Post-op bed options, PostOpNights defaults to one night.
+	PostOpWardID *uint `json:"post_op_ward_id"`
+	PostOpNights int   `json:"post_op_nights" binding:"gte=0"`
+
+	// Waitlist options. With Waitlist set, a request that finds no free slot
+	// is queued instead of rejected.
+	Waitlist       bool       `json:"waitlist"`
+	PreferredFrom  *time.Time `json:"preferred_from"`
+	PreferredUntil *time.Time `json:"preferred_until"`
+	AutoBook       bool       `json:"auto_book"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SurgerySeries links the staged or recurring surgeries of one treatment.
+// The stages are booked, cancelled and rescheduled together.
+type SurgerySeries struct {
+	gorm.Model
+	PatientID uint              `json:"patient_id" gorm:"index"`
+	Patient   Patient           `json:"patient" gorm:"foreignKey:PatientID"`
+	Name      string            `json:"name"`
+	Notes     string            `json:"notes"`
+	Stages    []SurgerySchedule `json:"stages,omitempty" gorm:"foreignKey:SurgerySeriesID"`
+}
+
+// SeriesRepeat books the single given stage Count times, IntervalDays apart.
+type SeriesRepeat struct {
+	Count        int `json:"count" binding:"required,min=2,max=52"`
+	IntervalDays int `json:"interval_days" binding:"required,min=1"`
+}
+
+type SurgerySeriesRequest struct {
+	Name   string                   `json:"name"`
+	Notes  string                   `json:"notes"`
+	Stages []SurgeryScheduleRequest `json:"stages" binding:"required,min=1,dive"`
+	Repeat *SeriesRepeat            `json:"repeat"`
+}
+
+// SurgerySeriesRescheduleRequest moves the first stage still to come to
+// ScheduledAt and every later stage by the same amount.
+type SurgerySeriesRescheduleRequest struct {
+	ScheduledAt time.Time `json:"scheduled_at" binding:"required"`
+	Reason      string    `json:"reason" binding:"required"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidSurgeryTransition = errors.New("invalid surgery status transition")
+
+var surgeryTransitions = map[SurgeryStatus][]SurgeryStatus{
+	SurgeryStatusScheduled: {
+		SurgeryStatusInProgress,
+		SurgeryStatusCancelled,
+		SurgeryStatusPostponed,
+		SurgeryStatusNoShow,
+	},
+	SurgeryStatusInProgress: {SurgeryStatusCompleted},
+	SurgeryStatusPostponed:  {SurgeryStatusScheduled, SurgeryStatusCancelled},
+}
+
+func (s SurgeryStatus) CanTransitionTo(next SurgeryStatus) bool {
+	for _, allowed := range surgeryTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo moves the surgery to next and records the actual start or end
+// time. Every status change of a SurgerySchedule must go through here.
+func (s *SurgerySchedule) TransitionTo(next SurgeryStatus, at time.Time) error {
+	if !s.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: cannot move surgery %d from %s to %s", ErrInvalidSurgeryTransition, s.ID, s.Status, next)
+	}
+
+	switch next {
+	case SurgeryStatusInProgress:
+		s.ActualStartAt = &at
+	case SurgeryStatusCompleted:
+		s.ActualEndAt = &at
+	}
+	s.Status = next
+	return nil
+}
+
+// PrepareReschedule checks that the surgery may be moved. Moving a Scheduled
+// surgery keeps its status, while a Postponed one goes back to Scheduled.
+func (s *SurgerySchedule) PrepareReschedule() error {
+	switch s.Status {
+	case SurgeryStatusScheduled:
+		return nil
+	case SurgeryStatusPostponed:
+		return s.TransitionTo(SurgeryStatusScheduled, time.Now())
+	default:
+		return fmt.Errorf("%w: cannot reschedule surgery %d in status %s", ErrInvalidSurgeryTransition, s.ID, s.Status)
+	}
+}
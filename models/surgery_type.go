@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+type SurgeryType struct {
+	gorm.Model
+	Code              string   `json:"code" gorm:"size:64;uniqueIndex" binding:"required"`
+	Name              string   `json:"name"`
+	BasePrice         Amount   `json:"base_price"`
+	DefaultDuration   int      `json:"default_duration" binding:"gte=0"`
+	DefaultDeposit    Amount   `json:"default_deposit"`
+	RequiredSpecialty string   `json:"required_specialty"`
+	RequiredEquipment []string `json:"required_equipment" gorm:"serializer:json"`
+	RequiredOTClass   string   `json:"required_ot_class"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "gorm.io/gorm"
+
+type TeamRole string
+
+const (
+	TeamRoleAnesthetist      TeamRole = "Anesthetist"
+	TeamRoleAssistantSurgeon TeamRole = "Assistant Surgeon"
+	TeamRoleScrubNurse       TeamRole = "Scrub Nurse"
+	TeamRoleCirculatingNurse TeamRole = "Circulating Nurse"
+)
+
+func (r TeamRole) IsValid() bool {
+	switch r {
+	case TeamRoleAnesthetist, TeamRoleAssistantSurgeon, TeamRoleScrubNurse, TeamRoleCirculatingNurse:
+		return true
+	}
+	return false
+}
+
+// SurgicalTeamMember assigns a clinician to a surgery in addition to the lead
+// surgeon held in SurgerySchedule.DoctorID.
+type SurgicalTeamMember struct {
+	gorm.Model
+	SurgeryScheduleID uint     `json:"surgery_schedule_id" gorm:"index"`
+	DoctorID          uint     `json:"doctor_id" gorm:"index" binding:"required"`
+	Doctor            Doctor   `json:"doctor" gorm:"foreignKey:DoctorID"`
+	Role              TeamRole `json:"role" binding:"required"`
+}
+
+type TeamMemberRequest struct {
+	DoctorID uint     `json:"doctor_id" binding:"required"`
+	Role     TeamRole `json:"role" binding:"required"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type TimeSlot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (s TimeSlot) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+func (s TimeSlot) Overlaps(other TimeSlot) bool {
+	return s.Start.Before(other.End) && other.Start.Before(s.End)
+}
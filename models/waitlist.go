@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WaitlistStatus string
+
+const (
+	WaitlistWaiting   WaitlistStatus = "Waiting"
+	WaitlistOffered   WaitlistStatus = "Offered"
+	WaitlistBooked    WaitlistStatus = "Booked"
+	WaitlistCancelled WaitlistStatus = "Cancelled"
+)
+
+// WaitlistPriorityOrderSQL orders waitlist entries by priority, then by age.
+const WaitlistPriorityOrderSQL = "CASE priority WHEN 'Emergency' THEN 0 WHEN 'Urgent' THEN 1 ELSE 2 END, id"
+
+// WaitlistEntry keeps a surgery request that could not be booked so it can be
+// retried when a slot frees up. The request is stored as it was submitted,
+// with surgery type defaults already applied. EarliestAt and LatestAt bound
+// the start times the patient accepts. An offered entry holds OfferedAt, the
+// start of the offered slot, until OfferExpiresAt.
+type WaitlistEntry struct {
+	gorm.Model
+	PatientID         uint                   `json:"patient_id" gorm:"index"`
+	DoctorID          uint                   `json:"doctor_id" gorm:"index"`
+	SurgeryType       string                 `json:"surgery_type"`
+	Priority          SurgeryPriority        `json:"priority" gorm:"size:20"`
+	EarliestAt        time.Time              `json:"earliest_at" gorm:"index"`
+	LatestAt          time.Time              `json:"latest_at" gorm:"index"`
+	AutoBook          bool                   `json:"auto_book"`
+	Status            WaitlistStatus         `json:"status" gorm:"size:20;index;default:'Waiting'"`
+	Reason            string                 `json:"reason"`
+	Request           SurgeryScheduleRequest `json:"request" gorm:"serializer:json"`
+	OfferedAt         *time.Time             `json:"offered_at"`
+	OfferExpiresAt    *time.Time             `json:"offer_expires_at"`
+	SurgeryScheduleID *uint                  `json:"surgery_schedule_id"`
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type BedStatus string
+
+const (
+	BedStatusAvailable   BedStatus = "Available"
+	BedStatusMaintenance BedStatus = "Maintenance"
+)
+
+func (s BedStatus) IsValid() bool {
+	return s == BedStatusAvailable || s == BedStatusMaintenance
+}
+
+// Ward groups the inpatient beds of one unit, e.g. a general ward, the ICU or
+// a post-op recovery ward.
+type Ward struct {
+	gorm.Model
+	Name  string `json:"name" binding:"required"`
+	Floor int    `json:"floor"`
+	Type  string `json:"type"`
+	Beds  []Bed  `json:"beds,omitempty" gorm:"foreignKey:WardID"`
+}
+
+// Bed is a single inpatient bed. Whether it is occupied or reserved follows
+// from admissions and reservations; Status only takes it out of service.
+type Bed struct {
+	gorm.Model
+	WardID uint      `json:"ward_id" gorm:"index"`
+	Label  string    `json:"label" binding:"required"`
+	Status BedStatus `json:"status" gorm:"size:20;default:'Available'"`
+}
+
+type AdmissionStatus string
+
+const (
+	AdmissionStatusAdmitted   AdmissionStatus = "Admitted"
+	AdmissionStatusDischarged AdmissionStatus = "Discharged"
+)
+
+// Admission is an inpatient stay. BedID and WardID follow the patient through
+// transfers, the history of which is kept in Transfers. An admission without
+// ExpectedDischargeAt holds its bed indefinitely.
+type Admission struct {
+	gorm.Model
+	PatientID            uint                 `json:"patient_id" gorm:"index"`
+	Patient              Patient              `json:"patient" gorm:"foreignKey:PatientID"`
+	WardID               uint                 `json:"ward_id" gorm:"index"`
+	BedID                uint                 `json:"bed_id" gorm:"index"`
+	Bed                  Bed                  `json:"bed" gorm:"foreignKey:BedID"`
+	DoctorID             *uint                `json:"doctor_id"`
+	Status               AdmissionStatus      `json:"status" gorm:"size:20;index;default:'Admitted'"`
+	Reason               string               `json:"reason"`
+	AdmittedAt           time.Time            `json:"admitted_at"`
+	ExpectedDischargeAt  *time.Time           `json:"expected_discharge_at"`
+	DischargedAt         *time.Time           `json:"discharged_at"`
+	DischargeDestination DischargeDestination `json:"discharge_destination,omitempty" gorm:"size:32"`
+	DischargeNotes       string               `json:"discharge_notes"`
+
+	// SurgeryScheduleID is set when the admission took up the post-op bed
+	// reserved for a surgery.
+	SurgeryScheduleID *uint `json:"surgery_schedule_id"`
+
+	Transfers []BedTransfer `json:"transfers,omitempty" gorm:"foreignKey:AdmissionID"`
+}
+
+// BedTransfer records a move of an admitted patient to another bed.
+type BedTransfer struct {
+	gorm.Model
+	AdmissionID   uint      `json:"admission_id" gorm:"index"`
+	FromWardID    uint      `json:"from_ward_id"`
+	FromBedID     uint      `json:"from_bed_id"`
+	ToWardID      uint      `json:"to_ward_id"`
+	ToBedID       uint      `json:"to_bed_id"`
+	TransferredAt time.Time `json:"transferred_at"`
+	Reason        string    `json:"reason"`
+}
+
+type BedReservationStatus string
+
+const (
+	BedReservationHeld      BedReservationStatus = "Held"
+	BedReservationFulfilled BedReservationStatus = "Fulfilled"
+)
+
+// BedReleasingSurgeryStatuses free the post-op bed held for a surgery. A
+// completed surgery keeps it, the patient still needs the bed.
+var BedReleasingSurgeryStatuses = []SurgeryStatus{
+	SurgeryStatusCancelled,
+	SurgeryStatusPostponed,
+	SurgeryStatusNoShow,
+}
+
+// BedReservation holds a post-op bed from the planned end of a surgery. It
+// is fulfilled when the patient is admitted to the bed.
+type BedReservation struct {
+	gorm.Model
+	BedID             uint                 `json:"bed_id" gorm:"index"`
+	Bed               Bed                  `json:"bed" gorm:"foreignKey:BedID"`
+	SurgeryScheduleID uint                 `json:"surgery_schedule_id" gorm:"uniqueIndex"`
+	PatientID         uint                 `json:"patient_id" gorm:"index"`
+	StartAt           time.Time            `json:"start_at"`
+	EndAt             time.Time            `json:"end_at"`
+	Status            BedReservationStatus `json:"status" gorm:"size:20;default:'Held'"`
+	AdmissionID       *uint                `json:"admission_id"`
+}
+
+type AdmissionRequest struct {
+	PatientID           uint       `json:"patient_id" binding:"required"`
+	WardID              uint       `json:"ward_id" binding:"required"`
+	BedID               *uint      `json:"bed_id"`
+	DoctorID            *uint      `json:"doctor_id"`
+	Reason              string     `json:"reason"`
+	ExpectedDischargeAt *time.Time `json:"expected_discharge_at"`
+}
+
+type BedTransferRequest struct {
+	WardID uint   `json:"ward_id" binding:"required"`
+	BedID  *uint  `json:"bed_id"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+type DischargeRequest struct {
+	Destination DischargeDestination `json:"discharge_destination"`
+	Notes       string               `json:"notes"`
+}
+
+type BedState string
+
+const (
+	BedStateAvailable   BedState = "Available"
+	BedStateOccupied    BedState = "Occupied"
+	BedStateReserved    BedState = "Reserved"
+	BedStateMaintenance BedState = "Maintenance"
+)
+
+// BedOccupancy is the current state of one bed in the occupancy view.
+type BedOccupancy struct {
+	BedID               uint            `json:"bed_id"`
+	Label               string          `json:"label"`
+	State               BedState        `json:"state"`
+	PatientID           *uint           `json:"patient_id,omitempty"`
+	AdmissionID         *uint           `json:"admission_id,omitempty"`
+	ExpectedDischargeAt *time.Time      `json:"expected_discharge_at,omitempty"`
+	NextReservation     *BedReservation `json:"next_reservation,omitempty"`
+}
+
+type WardOccupancy struct {
+	WardID        uint           `json:"ward_id"`
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	TotalBeds     int            `json:"total_beds"`
+	InService     int            `json:"in_service"`
+	Occupied      int            `json:"occupied"`
+	Reserved      int            `json:"reserved"`
+	Available     int            `json:"available"`
+	OccupancyRate float64        `json:"occupancy_rate"`
+	Beds          []BedOccupancy `json:"beds"`
+}
@@ -21,6 +21,30 @@ func SetupRouter() *gin.Engine {
 	router.PATCH("/doctor/:id", controllers.UpdateDoctor)
 	router.DELETE("/doctor/:id", controllers.DeleteDoctor)
 	router.GET("/searchDoctorByName", controllers.SearchDoctorByName)
+	router.POST("/doctor/:id/specialties", controllers.AddDoctorSpecialty)
+	router.DELETE("/doctor/:id/specialties/:specialty_id", controllers.RemoveDoctorSpecialty)
+	router.GET("/doctor/:id/privileges", controllers.GetDoctorPrivileges)
+	router.POST("/doctor/:id/privileges", controllers.GrantDoctorPrivilege)
+	router.DELETE("/doctor/:id/privileges/:surgery_type_id", controllers.RevokeDoctorPrivilege)
+
+	router.GET("/doctor/:id/notifications", controllers.GetDoctorNotifications)
+	router.POST("/notification/:id/read", controllers.MarkNotificationRead)
+
+	// Doctor Roster Routes
+	router.GET("/doctor/:id/roster", controllers.GetDoctorRoster)
+	router.POST("/doctor/:id/working-hours", controllers.CreateDoctorWorkingHours)
+	router.PATCH("/doctor/:id/working-hours/:hours_id", controllers.UpdateDoctorWorkingHours)
+	router.DELETE("/doctor/:id/working-hours/:hours_id", controllers.DeleteDoctorWorkingHours)
+	router.POST("/doctor/:id/shifts", controllers.CreateDoctorShift)
+	router.PATCH("/doctor/:id/shifts/:shift_id", controllers.UpdateDoctorShift)
+	router.DELETE("/doctor/:id/shifts/:shift_id", controllers.DeleteDoctorShift)
+	router.POST("/doctor/:id/leave", controllers.CreateDoctorLeave)
+	router.PATCH("/doctor/:id/leave/:leave_id", controllers.UpdateDoctorLeave)
+	router.DELETE("/doctor/:id/leave/:leave_id", controllers.DeleteDoctorLeave)
+
+	// Specialty Routes
+	router.GET("/specialties/", controllers.GetAllSpecialties)
+	router.POST("/specialty/", controllers.CreateSpecialty)
 
 	// Patient Routes
 	router.GET("/patients/", controllers.GetAllPatients)
@@ -31,6 +55,19 @@ func SetupRouter() *gin.Engine {
 	router.DELETE("/patient/:id", controllers.DeletePatient)
 	router.GET("/searchPatientByName", controllers.SearchPatientByName)
 
+	// Deposit Ledger Routes
+	router.POST("/patient/:id/deposit/topup", controllers.TopUpDeposit)
+	router.POST("/patient/:id/deposit/adjustment", controllers.AdjustDeposit)
+	router.GET("/patient/:id/deposit/transactions", controllers.GetDepositTransactions)
+	router.GET("/deposits/reconciliation", controllers.ReconcileDeposits)
+
+	// Billing Routes
+	router.GET("/invoice/:id", controllers.GetInvoiceByID)
+	router.POST("/invoice/:id/payments", controllers.RecordPayment)
+	router.GET("/invoices/patient/:patient_id", controllers.GetInvoicesByPatient)
+	router.GET("/invoices/surgery/:surgery_id", controllers.GetInvoiceBySurgery)
+	router.GET("/patient/:id/balance", controllers.GetPatientBalance)
+
 	// Operating Theater Routes
 	router.POST("/operating-theater/", controllers.CreateOperatingTheater)
 	router.GET("/operating-theater/:id", controllers.GetOperatingTheaterByID)
@@ -38,15 +75,111 @@ func SetupRouter() *gin.Engine {
 	router.GET("/operating-theaters/available", controllers.GetAvailableOperatingTheaters)
 	router.PATCH("/operating-theater/:id", controllers.UpdateOperatingTheater)
 	router.DELETE("/operating-theater/:id", controllers.DeleteOperatingTheater)
+	router.POST("/operating-theater/:id/equipment", controllers.AddOperatingTheaterEquipment)
+	router.DELETE("/operating-theater/:id/equipment/:equipment_id", controllers.RemoveOperatingTheaterEquipment)
+	router.GET("/operating-theater/:id/maintenance", controllers.GetOperatingTheaterMaintenance)
+	router.POST("/operating-theater/:id/maintenance", controllers.ScheduleOperatingTheaterMaintenance)
+	router.DELETE("/operating-theater/:id/maintenance/:window_id", controllers.CancelOperatingTheaterMaintenance)
+
+	// Equipment Inventory Routes
+	router.GET("/equipment/", controllers.GetAllEquipment)
+	router.POST("/equipment/", controllers.CreateEquipment)
+	router.PATCH("/equipment/:id", controllers.UpdateEquipment)
+	router.DELETE("/equipment/:id", controllers.DeleteEquipment)
+	router.GET("/equipment/:id/bookings", controllers.GetEquipmentBookings)
+
+	// Surgery Type Routes
+	router.POST("/surgery-type/", controllers.CreateSurgeryType)
+	router.GET("/surgery-types/", controllers.GetAllSurgeryTypes)
+	router.GET("/surgery-type/:id", controllers.GetSurgeryTypeByID)
+	router.PATCH("/surgery-type/:id", controllers.UpdateSurgeryType)
+	router.DELETE("/surgery-type/:id", controllers.DeleteSurgeryType)
+
+	// Cancellation Policy Routes
+	router.POST("/cancellation-policy/", controllers.CreateCancellationPolicyRule)
+	router.GET("/cancellation-policies/", controllers.GetCancellationPolicyRules)
+	router.PATCH("/cancellation-policy/:id", controllers.UpdateCancellationPolicyRule)
+	router.DELETE("/cancellation-policy/:id", controllers.DeleteCancellationPolicyRule)
+
+	// Pre-op Checklist Template Routes
+	router.POST("/checklist-template/", controllers.CreateChecklistTemplateItem)
+	router.GET("/checklist-templates/", controllers.GetChecklistTemplateItems)
+	router.PATCH("/checklist-template/:id", controllers.UpdateChecklistTemplateItem)
+	router.DELETE("/checklist-template/:id", controllers.DeleteChecklistTemplateItem)
+
+	// Waitlist Routes
+	router.GET("/waitlist/", controllers.GetWaitlist)
+	router.POST("/waitlist/", controllers.AddWaitlistEntry)
+	router.GET("/waitlist/:id", controllers.GetWaitlistEntryByID)
+	router.POST("/waitlist/:id/accept", controllers.AcceptWaitlistOffer)
+	router.POST("/waitlist/:id/decline", controllers.DeclineWaitlistOffer)
+	router.DELETE("/waitlist/:id", controllers.CancelWaitlistEntry)
 
 	// Surgery Scheduling Routes (Transactional)
-	router.POST("/surgery/schedule", controllers.ScheduleSurgery)           // Schedule a new surgery (THE MAIN TRANSACTION)
-	router.POST("/surgery/:id/complete", controllers.CompleteSurgery)       // Mark surgery as completed
-	router.POST("/surgery/:id/cancel", controllers.CancelSurgery)           // Cancel surgery and refund deposit
-	router.GET("/surgery/:id", controllers.GetSurgeryByID)                  // Get surgery details
-	router.GET("/surgeries/", controllers.GetAllSurgeries)                  // Get all surgeries
-	router.GET("/surgeries/doctor/:doctor_id", controllers.GetSurgeriesByDoctor)   // Get surgeries by doctor
+	router.POST("/surgery/schedule", controllers.ScheduleSurgery)          // Schedule a new surgery (THE MAIN TRANSACTION)
+	router.GET("/surgery/suggestions", controllers.GetSurgerySuggestions)  // Earliest feasible slots for a surgery
+	router.POST("/surgery/optimize", controllers.OptimizeSurgerySchedule)  // Plan a day's list, previewed unless commit is set
+	router.POST("/surgery/:id/start", controllers.StartSurgery)            // Mark surgery as in progress
+	router.POST("/surgery/:id/complete", controllers.CompleteSurgery)      // Mark surgery as completed
+	router.POST("/surgery/:id/cancel", controllers.CancelSurgery)          // Cancel surgery, refunding per cancellation policy
+	router.POST("/surgery/:id/postpone", controllers.PostponeSurgery)      // Postpone surgery, keeping the deposit
+	router.POST("/surgery/:id/no-show", controllers.MarkSurgeryNoShow)     // Patient did not turn up
+	router.PATCH("/surgery/:id/reschedule", controllers.RescheduleSurgery) // Move time, doctor or theater, keeping the deposit
+	router.POST("/surgery/:id/consumables", controllers.AddSurgeryConsumable)
+	router.GET("/surgery/:id/consumables", controllers.GetSurgeryConsumables)
+	router.GET("/surgery/:id/team", controllers.GetSurgicalTeam)
+	router.POST("/surgery/:id/team", controllers.AddSurgicalTeamMember)
+	router.DELETE("/surgery/:id/team/:member_id", controllers.RemoveSurgicalTeamMember)
+	router.GET("/surgery/:id/checklist", controllers.GetSurgeryChecklist)
+	router.POST("/surgery/:id/checklist", controllers.AddSurgeryChecklistItem)
+	router.PATCH("/surgery/:id/checklist/:item_id", controllers.UpdateSurgeryChecklistItem)
+	router.DELETE("/surgery/:id/checklist/:item_id", controllers.DeleteSurgeryChecklistItem)
+	router.GET("/surgery/:id/operative-report", controllers.GetOperativeReport)
+	router.GET("/surgery/:id", controllers.GetSurgeryByID)                          // Get surgery details
+	router.GET("/surgeries/", controllers.GetAllSurgeries)                          // Get all surgeries
+	router.GET("/surgeries/doctor/:doctor_id", controllers.GetSurgeriesByDoctor)    // Get surgeries by doctor
 	router.GET("/surgeries/patient/:patient_id", controllers.GetSurgeriesByPatient) // Get surgeries by patient
+	router.GET("/operative-reports/", controllers.GetOperativeReports)              // Operative reports with actual vs estimated durations
+	router.GET("/surgeries/doctor/:doctor_id/calendar.ics", controllers.GetDoctorSurgeryCalendar)
+	router.GET("/surgeries/operating-theater/:operating_theater_id/calendar.ics", controllers.GetOperatingTheaterSurgeryCalendar)
+
+	// Surgery Series Routes (staged or recurring surgeries booked together)
+	router.POST("/surgery-series/", controllers.ScheduleSurgerySeries)
+	router.GET("/surgery-series/:id", controllers.GetSurgerySeriesByID)
+	router.POST("/surgery-series/:id/cancel", controllers.CancelSurgerySeries)
+	router.PATCH("/surgery-series/:id/reschedule", controllers.RescheduleSurgerySeries)
+	router.GET("/patient/:id/surgery-series", controllers.GetPatientSurgerySeries)
+
+	// Appointment Routes (outpatient slots against the doctor roster)
+	router.POST("/appointment/", controllers.BookAppointment)
+	router.GET("/appointments/", controllers.GetAllAppointments)
+	router.GET("/appointment/:id", controllers.GetAppointmentByID)
+	router.PATCH("/appointment/:id", controllers.RescheduleAppointment)
+	router.POST("/appointment/:id/check-in", controllers.CheckInAppointment)
+	router.POST("/appointment/:id/seen", controllers.MarkAppointmentSeen)
+	router.POST("/appointment/:id/no-show", controllers.MarkAppointmentNoShow)
+	router.POST("/appointment/:id/cancel", controllers.CancelAppointment)
+	router.GET("/doctor/:id/appointments", controllers.GetDoctorAppointments)
+	router.GET("/doctor/:id/appointment-slots", controllers.GetDoctorAppointmentSlots)
+	router.GET("/patient/:id/appointments", controllers.GetPatientAppointments)
+
+	// Ward and Bed Routes
+	router.POST("/ward/", controllers.CreateWard)
+	router.GET("/wards/", controllers.GetAllWards)
+	router.GET("/wards/occupancy", controllers.GetAllWardOccupancy)
+	router.GET("/ward/:id", controllers.GetWardByID)
+	router.PATCH("/ward/:id", controllers.UpdateWard)
+	router.POST("/ward/:id/beds", controllers.AddWardBed)
+	router.PATCH("/ward/:id/beds/:bed_id", controllers.UpdateWardBed)
+	router.GET("/ward/:id/occupancy", controllers.GetWardOccupancy)
+
+	// Admission Routes (inpatient stays, with bed transfers and discharge)
+	router.POST("/admission/", controllers.AdmitPatient)
+	router.GET("/admissions/", controllers.GetAllAdmissions)
+	router.GET("/admission/:id", controllers.GetAdmissionByID)
+	router.POST("/admission/:id/transfer", controllers.TransferAdmission)
+	router.POST("/admission/:id/discharge", controllers.DischargeAdmission)
+	router.GET("/patient/:id/admissions", controllers.GetPatientAdmissions)
 
 	return router
 }